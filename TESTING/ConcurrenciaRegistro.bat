@@ -0,0 +1,82 @@
+@echo off
+setlocal EnableDelayedExpansion
+
+REM Registra N funciones en paralelo para el mismo usuario y comprueba que
+REM no se pierde ninguna. Se desactivan la cuota de funciones y se suben los
+REM limites de peticiones para que no interfieran.
+set N=300
+set QUOTA_MAX_FUNCTIONS=0
+set RATE_LIMIT_PLANS={"default": {"api.user": {"rate": 1000, "burst": 1000}, "api.ip": {"rate": 1000, "burst": 1000}}}
+
+echo Directorio actual: %CD%
+docker compose up --build -d
+timeout /t 5
+
+echo _________________________________________________________________________
+echo Creando usuario de prueba...
+curl -X POST http://localhost:9080/register ^
+     -H "Content-Type: application/json" ^
+     -d "{\"username\":\"concurrencia\",\"password\":\"concurrencia1\"}"
+
+curl -s -X POST http://localhost:9080/login ^
+     -H "Content-Type: application/json" ^
+     -d "{\"username\":\"concurrencia\",\"password\":\"concurrencia1\"}" > login_response.json
+
+FOR /F "delims=" %%i IN ('powershell -NoProfile -Command ^
+    "(Get-Content -Path 'login_response.json' | ConvertFrom-Json).token"') DO SET TOKEN=%%i
+DEL login_response.json
+
+if "%TOKEN%"=="" (
+    echo Error: no se ha obtenido el token
+    exit /b 1
+)
+
+echo _________________________________________________________________________
+echo Registrando %N% funciones en paralelo...
+if exist registro_resultados rmdir /s /q registro_resultados
+mkdir registro_resultados
+for /l %%i in (1,1,%N%) do (
+    start "" /b curl -s -o registro_resultados\%%i.json -w "%%{http_code}" ^
+         -X POST http://localhost:9080/function ^
+         -H "Content-Type: application/json" ^
+         -H "Authorization: Bearer %TOKEN%" ^
+         -d "{\"name\": \"concurrencia%%i\", \"ownerId\": \"concurrencia\", \"image\": \"pablogranell/emociones\"}" > registro_resultados\%%i.status
+)
+
+:esperar
+timeout /t 2 > nul
+tasklist /fi "imagename eq curl.exe" | find /i "curl.exe" > nul
+if not errorlevel 1 goto esperar
+
+echo _________________________________________________________________________
+echo Comprobando resultados...
+set CREADAS=0
+for /l %%i in (1,1,%N%) do (
+    set /p STATUS=<registro_resultados\%%i.status
+    if "!STATUS!"=="201" set /a CREADAS+=1
+)
+
+curl -s -X GET "http://localhost:9080/functions?username=concurrencia" ^
+     -H "Authorization: Bearer %TOKEN%" > funciones.json
+FOR /F "delims=" %%i IN ('powershell -NoProfile -Command ^
+    "@(Get-Content -Raw -Path 'funciones.json' | ConvertFrom-Json).Count"') DO SET GUARDADAS=%%i
+DEL funciones.json
+
+echo Registros aceptados (201): %CREADAS%
+echo Funciones guardadas:       %GUARDADAS%
+if "%CREADAS%"=="%GUARDADAS%" (
+    echo OK: no se ha perdido ninguna funcion
+) else (
+    echo ERROR: se han perdido funciones
+)
+
+echo _________________________________________________________________________
+echo Limpieza...
+for /l %%i in (1,1,%N%) do (
+    curl -s -o nul -X DELETE http://localhost:9080/function/concurrencia%%i ^
+         -H "Authorization: Bearer %TOKEN%"
+)
+rmdir /s /q registro_resultados
+
+echo Prueba completada.
+pause
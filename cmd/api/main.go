@@ -1,40 +1,118 @@
 package main
 
 import (
+	"context"
 	"faas-project/internal/api/handlers"
+	"faas-project/internal/auth"
+	"faas-project/internal/health"
+	"faas-project/internal/logging"
 	"faas-project/internal/message"
+	"faas-project/internal/metering"
+	"faas-project/internal/metrics"
 	"faas-project/internal/middleware"
-	"fmt"
+	"faas-project/internal/repository"
+	"faas-project/internal/tracing"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 func main() {
+	var draining atomic.Bool
+	logger := logging.Init("api-server")
 
 	nc, err := message.Connect("nats://nats:4222")
 	if err != nil {
-		fmt.Println(err)
+		logger.Error("error al conectar con NATS", "error", err)
+		os.Exit(1)
+	}
+	if err := message.InitNats(nc); err != nil {
+		logger.Error("error al iniciar JetStream", "error", err)
+		os.Exit(1)
+	}
+	hostname, _ := os.Hostname()
+	if err := repository.Migrate(context.Background(), message.GetJetStream(), hostname, logger); err != nil {
+		logger.Error("error al migrar los datos", "error", err)
+		os.Exit(1)
+	}
+
+	storage, err := repository.OpenStorage(nc, message.GetJetStream())
+	if err != nil {
+		logger.Error("error al abrir el almacenamiento", "error", err)
+		os.Exit(1)
+	}
+	defer storage.Close()
+	logger.Info("almacenamiento abierto", "backend", storage.Backend)
+	middleware.SetUserRepository(storage.Users)
+	h := handlers.New(storage.Functions, storage.Users, repository.NewNatsFunctionRepository(nc, message.GetJetStream()))
+
+	shutdownTracing, err := tracing.Init(context.Background(), "api-server")
+	if err != nil {
+		logger.Error("error al iniciar las trazas", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	if _, err := metering.StartAggregator(message.GetJetStream()); err != nil {
+		logger.Error("error al iniciar el agregador de uso", "error", err)
+		os.Exit(1)
+	}
+
+	if err := auth.InitOIDC(auth.LoadOIDCConfig()); err != nil {
+		logger.Error("error al iniciar OIDC", "error", err)
+		os.Exit(1)
+	}
+
+	metrics.RegisterAPI(nc)
+	http.Handle("/metrics", metrics.Handler())
+	http.HandleFunc("/healthz", health.Handler(health.NATS(nc)))
+	// Whatever the storage backend, the rest of the state stays in JetStream
+	http.HandleFunc("/readyz", health.Handler(health.Draining(&draining), health.NATS(nc),
+		health.KeyValue(message.GetJetStream(), "login_attempts", "rate_limits", "usage", "usage_executions", "platform_config", "registry_credentials"),
+		health.Check{Name: "storage", Run: storage.Ping}))
+
+	// The unversioned paths are kept as aliases of /v1 for older clients
+	rt := routes(h)
+	http.Handle("/v1/", http.StripPrefix("/v1", rt.Handler(true)))
+	http.Handle("/", rt.Handler(false))
+
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: middleware.RequestID(http.DefaultServeMux),
+	}
+	// Log streams never end on their own, Shutdown would wait for them
+	server.RegisterOnShutdown(handlers.StopStreams)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("servidor iniciado", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		logger.Error("error en el servidor HTTP", "error", err)
 		return
+	case <-sigChan:
+	}
+
+	// Readiness fails from now on and in-flight requests, including
+	// invocations waiting for a worker, get until the deadline to finish
+	timeout := health.ShutdownTimeout(time.Duration(repository.REQUEST_TTL+5) * time.Second)
+	logger.Info("deteniendo el servidor", "timeout", timeout.String())
+	draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("error al detener el servidor HTTP", "error", err)
 	}
-	message.InitNats(nc)
-
-	http.HandleFunc("/", handlers.DefaultHandler)
-	http.HandleFunc("/login", handlers.LoginHandler)
-	http.HandleFunc("/register", handlers.RegisterHandler)
-	http.HandleFunc("/function", middleware.JWTMiddleware(handlers.RegisterFunctionHandler))
-	http.HandleFunc("/function/", middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodDelete:
-			handlers.DeleteFunctionHandler(w, r)
-		case http.MethodPost:
-			handlers.ExecuteFunctionHandler(w, r)
-		default:
-			http.NotFound(w, r)
-		}
-	}))
-	http.HandleFunc("/functions", middleware.JWTMiddleware(handlers.GetFunctionsByUserHandler))
-
-	fmt.Println("Starting server at port 8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		fmt.Println(err)
+	if err := nc.Drain(); err != nil {
+		logger.Error("error al drenar la conexión con NATS", "error", err)
 	}
+	logger.Info("servidor detenido")
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"faas-project/internal/api/handlers"
+	"faas-project/internal/api/router"
+	"faas-project/internal/auth"
+	"faas-project/internal/middleware"
+	"faas-project/internal/models"
+	"net/http"
+)
+
+// Request and response bodies that have no model of their own, described
+// here for the OpenAPI document.
+type (
+	messageResult struct {
+		Status  string `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	loginResult struct {
+		Status  string `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Token   string `json:"token"`
+	}
+	invocation struct {
+		Param string `json:"param"`
+	}
+	invocationResult struct {
+		Status string `json:"status"`
+		Result string `json:"result"`
+	}
+	executionPage struct {
+		Executions []models.Execution `json:"executions"`
+		Next       string             `json:"next,omitempty"`
+	}
+	functionStats struct {
+		Function  string                  `json:"function"`
+		Window    models.ExecutionStats   `json:"window"`
+		Intervals []models.ExecutionStats `json:"intervals,omitempty"`
+	}
+	passwordChange struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	passwordReset struct {
+		Username    string `json:"username"`
+		NewPassword string `json:"newPassword"`
+	}
+	planAssignment struct {
+		Username string `json:"username"`
+		Plan     string `json:"plan"`
+	}
+	usage struct {
+		User      string               `json:"user"`
+		Functions int                  `json:"functions"`
+		Today     models.UsageCounters `json:"today"`
+		Month     models.UsageCounters `json:"month"`
+		Quota     models.Quota         `json:"quota"`
+	}
+)
+
+var (
+	yamlTypes     = []string{"application/yaml", "application/x-yaml", "text/yaml"}
+	manifestTypes = append([]string{"application/json"}, yamlTypes...)
+)
+
+// routes builds the API served under /v1 and, for older clients, without
+// the prefix.
+func routes(h *handlers.Handlers) *router.Router {
+	// protected wraps an authenticated control-plane route
+	protected := func(action string, next http.HandlerFunc) http.HandlerFunc {
+		return middleware.JWTMiddleware(middleware.RateLimit(middleware.ScopeAPI, middleware.Audit(action, next)))
+	}
+	public := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.RateLimit(middleware.ScopeAuth, next)
+	}
+	since := router.QueryParam{Name: "since", Description: "Fecha RFC 3339 o duración hasta ahora (10m)"}
+	limit := router.QueryParam{Name: "limit", Description: "Número máximo de resultados", Type: "integer"}
+	namespace := router.QueryParam{Name: "namespace", Description: "Namespace de destino (solo administradores)"}
+	dryRun := router.QueryParam{Name: "dryRun", Description: "Calcula los cambios sin aplicarlos", Type: "boolean"}
+	prune := router.QueryParam{Name: "prune", Description: "Elimina las funciones que no aparecen", Type: "boolean"}
+	auditQuery := []router.QueryParam{
+		{Name: "user", Description: "Usuario que hizo la acción"},
+		{Name: "function", Description: "Función afectada"},
+		{Name: "since", Description: "Fecha RFC 3339"},
+		{Name: "until", Description: "Fecha RFC 3339"},
+		limit,
+	}
+
+	rt := router.New()
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/", Handler: handlers.DefaultHandler,
+		Summary: "Comprueba que la API responde", Tag: "general",
+		Produces: []string{"text/plain"},
+	})
+	if !auth.LocalLoginDisabled() {
+		rt.Handle(router.Route{
+			Method: http.MethodPost, Path: "/login", Handler: public(h.LoginHandler),
+			Summary: "Inicia sesión con usuario y contraseña", Tag: "usuarios",
+			Body: credentials{}, Response: loginResult{},
+		})
+		rt.Handle(router.Route{
+			Method: http.MethodPost, Path: "/register", Handler: public(h.RegisterHandler),
+			Summary: "Registra un usuario", Tag: "usuarios",
+			Body: credentials{}, Response: messageResult{}, Status: http.StatusCreated,
+		})
+	}
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/oidc/login", Handler: public(h.OIDCLoginHandler),
+		Summary: "Redirige al proveedor de identidad", Tag: "usuarios", Status: http.StatusFound,
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/oidc/callback", Handler: public(h.OIDCCallbackHandler),
+		Summary: "Completa el login OIDC", Tag: "usuarios",
+		Query:    []router.QueryParam{{Name: "code"}, {Name: "state"}},
+		Response: loginResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/password", Handler: protected("user.password.change", h.ChangePasswordHandler),
+		Summary: "Cambia la contraseña propia", Tag: "usuarios", Auth: true,
+		Body: passwordChange{}, Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/admin/password", Handler: protected("user.password.reset", h.AdminResetPasswordHandler),
+		Summary: "Restablece la contraseña de un usuario", Tag: "administración", Auth: true,
+		Body: passwordReset{}, Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/admin/plan", Handler: protected("user.plan", h.AdminSetPlanHandler),
+		Summary: "Asigna un plan de límites a un usuario", Tag: "administración", Auth: true,
+		Body: planAssignment{}, Response: messageResult{},
+	})
+
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/function", Handler: protected("function.register", h.RegisterFunctionHandler),
+		Summary: "Registra una función", Tag: "funciones", Auth: true,
+		Body: models.Function{}, Response: messageResult{}, Status: http.StatusCreated,
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/functions", Handler: protected("function.list", h.GetFunctionsByUserHandler),
+		Summary: "Lista las funciones del usuario del token (total en X-Total-Count, siguiente página en X-Next-Cursor)", Tag: "funciones", Auth: true,
+		Query: []router.QueryParam{
+			{Name: "label", Description: "Filtro clave o clave=valor; repetible"},
+			{Name: "sort", Description: "name, createdAt o updatedAt; con - delante, descendente"},
+			{Name: "limit", Description: "Máximo de funciones por página (todas si se omite)", Type: "integer"},
+			{Name: "cursor", Description: "Valor de X-Next-Cursor de la página anterior"},
+			{Name: "username", Description: "Obsoleto; si se indica debe ser el usuario del token"},
+		},
+		Response: []models.Function{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/function/{name}", Handler: protected("function.read", h.GetFunctionHandler),
+		Summary: "Detalle de una función: definición, versión, digest de la imagen y última invocación", Tag: "funciones", Auth: true,
+		Response: models.FunctionStatus{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/function/{name}",
+		Handler: middleware.JWTMiddleware(middleware.RateLimit(middleware.ScopeInvoke, middleware.Audit("function.invoke", h.ExecuteFunctionHandler))),
+		Summary: "Invoca una función", Tag: "funciones", Auth: true,
+		Body: invocation{}, Response: invocationResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodDelete, Path: "/function/{name}", Handler: protected("function.delete", h.DeleteFunctionHandler),
+		Summary: "Elimina una función", Tag: "funciones", Auth: true,
+		Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/function/{name}/logs", Handler: protected("function.logs", h.FunctionLogsHandler),
+		Summary: "Consulta o sigue (follow=true, SSE) los logs de una función", Tag: "funciones", Auth: true,
+		Query: []router.QueryParam{
+			since, limit,
+			{Name: "execution", Description: "ID de la ejecución"},
+			{Name: "follow", Description: "Envía los logs nuevos como Server-Sent Events", Type: "boolean"},
+		},
+		Response: []models.LogEntry{},
+		Produces: []string{"application/json", "text/event-stream"},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/function/{name}/executions", Handler: protected("function.executions", h.FunctionExecutionsHandler),
+		Summary: "Historial de invocaciones de una función", Tag: "funciones", Auth: true,
+		Query: []router.QueryParam{
+			since, limit,
+			{Name: "until", Description: "Fecha RFC 3339"},
+			{Name: "caller", Description: "Usuario que invocó"},
+			{Name: "status", Description: "Estado de la ejecución"},
+			{Name: "cursor", Description: "Valor de next de la página anterior"},
+		},
+		Response: executionPage{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/function/{name}/stats", Handler: protected("function.stats", h.FunctionStatsHandler),
+		Summary: "Estadísticas de invocación de una función", Tag: "funciones", Auth: true,
+		Query: []router.QueryParam{
+			{Name: "window", Description: "Duración de la ventana (24h por defecto)"},
+			{Name: "interval", Description: "Duración de cada intervalo"},
+		},
+		Response: functionStats{},
+	})
+
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/export", Handler: protected("function.export", h.ExportHandler),
+		Summary: "Exporta las funciones de un namespace", Tag: "manifiestos", Auth: true,
+		Query:    []router.QueryParam{namespace, {Name: "format", Description: "yaml para exportar en YAML"}},
+		Response: models.Manifest{},
+		Produces: manifestTypes,
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/import", Handler: protected("function.import", h.ImportHandler),
+		Summary: "Importa un manifiesto exportado", Tag: "manifiestos", Auth: true,
+		Query: []router.QueryParam{namespace, dryRun, prune},
+		Body:  models.Manifest{}, Response: models.ImportResult{},
+		Consumes: manifestTypes,
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPost, Path: "/apply", Handler: protected("function.apply", h.ApplyHandler),
+		Summary: "Reconcilia el namespace con un faas.yaml", Tag: "manifiestos", Auth: true,
+		Query: []router.QueryParam{namespace, dryRun, prune},
+		Body:  models.FaasFile{}, Response: models.ImportResult{},
+		Consumes: manifestTypes,
+	})
+
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/admin/image-policy", Handler: protected("policy.image", h.ImagePolicyHandler),
+		Summary: "Consulta la política de imágenes", Tag: "administración", Auth: true,
+		Response: models.ImagePolicy{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPut, Path: "/admin/image-policy", Handler: protected("policy.image", h.ImagePolicyHandler),
+		Summary: "Reemplaza la política de imágenes", Tag: "administración", Auth: true,
+		Body: models.ImagePolicy{}, Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodPut, Path: "/registry-credentials", Handler: protected("registry.credentials", h.RegistryCredentialHandler),
+		Summary: "Guarda las credenciales de un registro privado", Tag: "administración", Auth: true,
+		Body: models.RegistryCredential{}, Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodDelete, Path: "/registry-credentials", Handler: protected("registry.credentials", h.RegistryCredentialHandler),
+		Summary: "Elimina las credenciales de un registro privado", Tag: "administración", Auth: true,
+		Body: models.RegistryCredential{}, Response: messageResult{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/usage", Handler: protected("usage.read", h.UsageHandler),
+		Summary: "Consumo y cuota del usuario", Tag: "uso", Auth: true,
+		Response: usage{},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/audit", Handler: protected("audit.read", h.AuditHandler),
+		Summary: "Consulta la auditoría (format=jsonl para JSON lines)", Tag: "administración", Auth: true,
+		Query:    append(auditQuery, router.QueryParam{Name: "format", Description: "jsonl para JSON lines"}),
+		Response: []models.AuditEvent{},
+		Produces: []string{"application/json", "application/x-ndjson"},
+	})
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/audit/export", Handler: protected("audit.export", h.AuditHandler),
+		Summary: "Descarga la auditoría como JSON lines", Tag: "administración", Auth: true,
+		Query:    auditQuery,
+		Produces: []string{"application/x-ndjson"},
+	})
+
+	// Generated once all the routes are in place
+	var document []byte
+	rt.Handle(router.Route{
+		Method: http.MethodGet, Path: "/openapi.json",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(document)
+		},
+		Summary: "Este documento", Tag: "general",
+	})
+	document, _ = json.MarshalIndent(rt.OpenAPI("FaaS API", "1", "/v1"), "", "  ")
+	return rt
+}
@@ -0,0 +1,124 @@
+// Command faasctl holds the admin tasks run against the platform's NATS
+// server:
+//
+//	faasctl backup [-o archivo] [-streams AUDIT,EXECUTIONS,USAGE]
+//	faasctl restore [-force] archivo
+//
+// restore creates the buckets and streams if needed and refuses to write
+// into ones that already hold data unless -force is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"faas-project/internal/backup"
+	"faas-project/internal/logging"
+	"faas-project/internal/message"
+
+	"github.com/nats-io/nats.go"
+)
+
+func main() {
+	defaultURL := os.Getenv("NATS_URL")
+	if defaultURL == "" {
+		defaultURL = "nats://nats:4222"
+	}
+	natsURL := flag.String("nats", defaultURL, "URL de NATS")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "uso: faasctl [-nats url] backup|restore [opciones]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := logging.Init("faasctl")
+	nc, err := nats.Connect(*natsURL)
+	if err != nil {
+		logger.Error("error al conectar con NATS", "error", err)
+		os.Exit(1)
+	}
+	defer nc.Close()
+
+	switch flag.Arg(0) {
+	case "backup":
+		err = runBackup(logger, nc, flag.Args()[1:])
+	case "restore":
+		err = runRestore(logger, nc, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Error("error en "+flag.Arg(0), "error", err)
+		os.Exit(1)
+	}
+}
+
+func runBackup(logger *slog.Logger, nc *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "faas-backup-"+time.Now().UTC().Format("20060102-150405")+".tar.gz", "archivo de salida")
+	streams := flags.String("streams", strings.Join(backup.DefaultStreams, ","), "streams a incluir, separados por comas (vacío para ninguno)")
+	flags.Parse(args)
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	var streamNames []string
+	if *streams != "" {
+		streamNames = strings.Split(*streams, ",")
+	}
+	manifest, err := backup.Write(file, js, streamNames)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	logger.Info("backup creado", "file", *output, "schema_version", manifest.SchemaVersion,
+		"buckets", manifest.Buckets, "streams", manifest.Streams)
+	return nil
+}
+
+func runRestore(logger *slog.Logger, nc *nats.Conn, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	force := flags.Bool("force", false, "escribe aunque los buckets o streams ya tengan datos")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("se esperaba el archivo de backup")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	manifest, files, err := backup.Read(file)
+	if err != nil {
+		return err
+	}
+
+	if err := message.InitNats(nc); err != nil {
+		return err
+	}
+	err = backup.Restore(message.GetJetStream(), manifest, files, backup.RestoreOptions{Force: *force})
+	if err != nil {
+		return err
+	}
+	logger.Info("backup restaurado", "file", flags.Arg(0), "created_at", manifest.CreatedAt,
+		"schema_version", manifest.SchemaVersion, "buckets", manifest.Buckets, "streams", manifest.Streams)
+	return nil
+}
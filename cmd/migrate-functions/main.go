@@ -0,0 +1,113 @@
+// Command migrate-functions copies the functions stored as one JSON array
+// per user in the legacy "user_functions" bucket to the "functions" bucket,
+// one key per function. It is safe to run more than once: functions that
+// already exist are skipped.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"faas-project/internal/logging"
+	"faas-project/internal/repository"
+
+	"github.com/nats-io/nats.go"
+)
+
+func main() {
+	defaultURL := os.Getenv("NATS_URL")
+	if defaultURL == "" {
+		defaultURL = "nats://nats:4222"
+	}
+	natsURL := flag.String("nats", defaultURL, "URL de NATS")
+	dryRun := flag.Bool("dry-run", false, "muestra lo que se migraría sin escribir nada")
+	deleteOld := flag.Bool("delete-old", false, "borra las entradas de user_functions ya migradas")
+	flag.Parse()
+
+	logger := logging.Init("migrate-functions")
+
+	nc, err := nats.Connect(*natsURL)
+	if err != nil {
+		logger.Error("error al conectar con NATS", "error", err)
+		os.Exit(1)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		logger.Error("error al obtener el contexto de JetStream", "error", err)
+		os.Exit(1)
+	}
+
+	legacy, err := js.KeyValue("user_functions")
+	if err == nats.ErrBucketNotFound {
+		logger.Info("no existe el bucket user_functions, nada que migrar")
+		return
+	}
+	if err != nil {
+		logger.Error("error al abrir el bucket user_functions", "error", err)
+		os.Exit(1)
+	}
+	if _, err := js.KeyValue("functions"); err == nats.ErrBucketNotFound && !*dryRun {
+		if _, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "functions"}); err != nil {
+			logger.Error("error al crear el bucket functions", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	owners, err := legacy.Keys()
+	if err == nats.ErrNoKeysFound {
+		logger.Info("el bucket user_functions está vacío, nada que migrar")
+		return
+	}
+	if err != nil {
+		logger.Error("error al listar user_functions", "error", err)
+		os.Exit(1)
+	}
+
+	functionRepository := repository.NewNatsFunctionRepository(nc, js)
+	var migrated, skipped, failed int
+	for _, owner := range owners {
+		entry, err := legacy.Get(owner)
+		if err != nil {
+			logger.Error("error al leer las funciones del usuario", "user", owner, "error", err)
+			failed++
+			continue
+		}
+		functions, err := repository.DecodeLegacyFunctions(owner, entry.Value())
+		if err != nil {
+			logger.Error("entrada con formato inválido", "user", owner, "error", err)
+			failed++
+			continue
+		}
+
+		ownerFailed := false
+		for _, function := range functions {
+			if *dryRun {
+				logger.Info("se migraría la función", "user", function.OwnerId, "function", function.Name)
+				migrated++
+				continue
+			}
+			err := functionRepository.CreateFunction(function)
+			switch {
+			case err == repository.ErrFunctionExists:
+				skipped++
+			case err != nil:
+				logger.Error("error al migrar la función", "user", function.OwnerId, "function", function.Name, "error", err)
+				ownerFailed = true
+				failed++
+			default:
+				migrated++
+			}
+		}
+		if *deleteOld && !*dryRun && !ownerFailed {
+			if err := legacy.Delete(owner); err != nil {
+				logger.Error("error al borrar la entrada antigua", "user", owner, "error", err)
+			}
+		}
+	}
+
+	logger.Info("migración terminada", "migrated", migrated, "skipped", skipped, "failed", failed, "dry_run", *dryRun)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"faas-project/internal/imagepolicy"
+	"faas-project/internal/logging"
+	"faas-project/internal/metering"
+	"faas-project/internal/metrics"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"faas-project/internal/sandbox"
+	"faas-project/internal/tracing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type channelWriter struct {
+	channel chan []byte
+}
+
+// Write hands the first chunk of output over as the reply. Later chunks are
+// dropped once nobody reads them, so the copy never blocks.
+func (cw channelWriter) Write(p []byte) (n int, err error) {
+	select {
+	case cw.channel <- append([]byte(nil), p...):
+	default:
+	}
+	return len(p), nil
+}
+
+type worker struct {
+	id                  string
+	nc                  *nats.Conn
+	js                  nats.JetStreamContext
+	docker              *client.Client
+	logger              *slog.Logger
+	sandboxPolicy       sandbox.Policy
+	policyRepository    *repository.NATSPolicyRepository
+	logRepository       *repository.NATSLogRepository
+	executionRepository *repository.NATSExecutionRepository
+	workerRepository    *repository.NATSWorkerRepository
+
+	// running holds the IDs of the executions in progress
+	running sync.Map
+
+	// ctx is cancelled when the shutdown deadline passes. While draining,
+	// pending messages are requeued instead of run.
+	ctx      context.Context
+	draining atomic.Bool
+}
+
+// functionEnv returns the environment of the container: the variables of
+// the function, sorted so runs are reproducible, and PARAM.
+func functionEnv(function models.Function, param string) []string {
+	env := make([]string, 0, len(function.Env)+1)
+	for name, value := range function.Env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return append(env, fmt.Sprintf("PARAM=%s", param))
+}
+
+// imagePullOptions adds the registry credentials of the function owner's
+// namespace, if any, to the pull.
+func imagePullOptions(policyRepository *repository.NATSPolicyRepository, function models.Function) (types.ImagePullOptions, error) {
+	image, err := imagepolicy.Parse(function.Image)
+	if err != nil {
+		return types.ImagePullOptions{}, err
+	}
+	credential, err := policyRepository.GetRegistryCredential(function.OwnerId, image.Registry)
+	if err == nats.ErrKeyNotFound {
+		return types.ImagePullOptions{}, nil
+	}
+	if err != nil {
+		return types.ImagePullOptions{}, err
+	}
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      credential.Username,
+		Password:      credential.Password,
+		ServerAddress: credential.Registry,
+	})
+	if err != nil {
+		return types.ImagePullOptions{}, err
+	}
+	return types.ImagePullOptions{RegistryAuth: auth}, nil
+}
+
+// handle runs one invocation received from the "functions.*" queue and
+// publishes the container output to the reply subject.
+func (wk *worker) handle(msg *nats.Msg) {
+	if wk.draining.Load() {
+		wk.requeue(msg)
+		return
+	}
+	nc := wk.nc
+	dockerClient := wk.docker
+	logger := wk.logger.With("request_id", msg.Header.Get(logging.RequestIDHeader))
+
+	// Every failure is answered, so the caller does not wait for the timeout
+	var req repository.ExecutionRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		logger.Error("error al deserializar la solicitud de ejecución", "error", err)
+		nc.Publish(msg.Reply, []byte("Solicitud de ejecución inválida: "+err.Error()))
+		return
+	}
+	logger = logger.With("function", req.Function.Name, "user", req.Function.OwnerId, "execution_id", req.ContainerId)
+
+	// wk.ctx is cancelled when the shutdown deadline passes
+	ctx, cancel := context.WithTimeout(tracing.ExtractNATS(wk.ctx, msg), 30*time.Second)
+	defer cancel()
+
+	// The reaper leaves containers of running executions alone
+	wk.running.Store(req.ContainerId, struct{}{})
+	defer wk.running.Delete(req.ContainerId)
+
+	// Every invocation leaves a record, also when it fails before the
+	// container starts
+	paramDigest := sha256.Sum256([]byte(req.Param))
+	execution := models.Execution{
+		ID:          req.ContainerId,
+		Namespace:   req.Function.OwnerId,
+		Function:    req.Function.Name,
+		Image:       req.Function.Image,
+		Version:     req.Function.Version,
+		Caller:      req.Caller,
+		ParamDigest: hex.EncodeToString(paramDigest[:]),
+		Start:       time.Now().UTC(),
+		ExitCode:    -1,
+		Status:      "error",
+		WorkerID:    wk.id,
+	}
+	defer func() {
+		execution.End = time.Now().UTC()
+		execution.Duration = execution.End.Sub(execution.Start)
+		if execution.Status != "success" && ctx.Err() == context.DeadlineExceeded {
+			execution.Status = "timeout"
+		}
+		if err := wk.executionRepository.Record(execution); err != nil {
+			logger.Error("error al guardar el registro de la ejecución", "error", err)
+		}
+	}()
+	ctx, span := tracing.Start(ctx, "worker.execute",
+		tracing.FunctionAttributes(req.Function.Name, req.ContainerId, req.Function.OwnerId)...)
+	defer span.End()
+
+	profile, err := wk.sandboxPolicy.Resolve(req.Function.Security)
+	if err != nil {
+		logger.Warn("perfil de seguridad rechazado", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	containerConfig := &container.Config{
+		Image:        req.Function.Image,
+		Env:          append(functionEnv(req.Function, req.Param), tracing.ContainerEnv(ctx)...),
+		Tty:          false,
+		AttachStdout: true,
+		AttachStderr: true,
+		Labels: map[string]string{
+			labelNamespace:   req.Function.OwnerId,
+			labelFunction:    req.Function.Name,
+			labelExecution:   req.ContainerId,
+			labelWorker:      wk.id,
+			labelCaller:      req.Caller,
+			labelParamDigest: execution.ParamDigest,
+			labelStarted:     execution.Start.Format(time.RFC3339Nano),
+			labelVersion:     strconv.FormatInt(req.Function.Version, 10),
+		},
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		containerConfig.Labels[labelDeadline] = deadline.UTC().Format(time.RFC3339)
+	}
+	hostConfig := &container.HostConfig{
+		AutoRemove: true,
+	}
+	if err := wk.sandboxPolicy.Apply(profile, containerConfig, hostConfig); err != nil {
+		logger.Error("error al aplicar el perfil de seguridad", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	memoryMB, err := wk.sandboxPolicy.MemoryLimit(req.Function.MemoryMB)
+	if err != nil {
+		logger.Warn("límite de memoria rechazado", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	hostConfig.Memory = memoryMB * 1024 * 1024
+
+	// The policy may have changed since the function was registered
+	imagePolicy, err := wk.policyRepository.GetImagePolicy()
+	if err != nil {
+		logger.Error("error al obtener la política de imágenes", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	if err := imagepolicy.Check(imagePolicy, req.Function.Image); err != nil {
+		logger.Warn("imagen rechazada", "image", req.Function.Image, "error", err)
+		nc.Publish(msg.Reply, []byte("Imagen rechazada: "+err.Error()))
+		return
+	}
+	pullOptions, err := imagePullOptions(wk.policyRepository, req.Function)
+	if err != nil {
+		logger.Error("error al obtener las credenciales del registro", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+
+	// Cold start when the image is not cached on this host yet
+	startKind := "warm"
+	if _, _, err := dockerClient.ImageInspectWithRaw(ctx, req.Function.Image); err != nil {
+		startKind = "cold"
+	}
+	pullStart := time.Now()
+	pullCtx, pullSpan := tracing.Start(ctx, "image.pull", attribute.String("faas.image", req.Function.Image), attribute.String("faas.start", startKind))
+	reader, err := dockerClient.ImagePull(pullCtx, req.Function.Image, pullOptions)
+	if err != nil {
+		logger.Error("no se ha encontrado la imagen en docker.io", "image", req.Function.Image, "error", err)
+		tracing.RecordError(pullSpan, err)
+		pullSpan.End()
+		nc.Publish(msg.Reply, []byte("Error al descargar la imagen: "+err.Error()))
+		return
+	}
+
+	_, err = io.Copy(io.Discard, reader)
+	reader.Close()
+	pullSpan.End()
+	if err != nil {
+		logger.Error("error al copiar la salida del pull", "error", err)
+		nc.Publish(msg.Reply, []byte("Error al descargar la imagen: "+err.Error()))
+		return
+	}
+	metrics.ImagePullDuration.Observe(metrics.Since(pullStart))
+	logger.Debug("imagen descargada", "image", req.Function.Image, "start", startKind, "duration_ms", time.Since(pullStart).Milliseconds())
+	image, _, err := dockerClient.ImageInspectWithRaw(ctx, req.Function.Image)
+	if err != nil {
+		logger.Error("error al inspeccionar la imagen", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	execution.ImageID = image.ID
+	execution.ImageDigest = imageDigest(image, req.Function.Image)
+	if err := imagepolicy.CheckSize(imagePolicy, image.Size); err != nil {
+		logger.Warn("imagen rechazada", "image", req.Function.Image, "error", err)
+		nc.Publish(msg.Reply, []byte("Imagen rechazada: "+err.Error()))
+		return
+	}
+	imageUser := ""
+	if image.Config != nil {
+		imageUser = image.Config.User
+	}
+	if err := wk.sandboxPolicy.CheckImageUser(profile, imageUser); err != nil {
+		logger.Warn("perfil de seguridad rechazado", "image", req.Function.Image, "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+	createCtx, createSpan := tracing.Start(ctx, "container.create")
+	resp, err := dockerClient.ContainerCreate(createCtx, containerConfig, hostConfig, nil, nil, req.ContainerId)
+	if err != nil {
+		logger.Error("error al crear el contenedor", "error", err)
+		tracing.RecordError(createSpan, err)
+		createSpan.End()
+		nc.Publish(msg.Reply, []byte("Error al crear el contenedor: "+err.Error()))
+		return
+	}
+	createSpan.End()
+	start := time.Now()
+	startCtx, startSpan := tracing.Start(ctx, "container.start")
+	err = dockerClient.ContainerStart(startCtx, resp.ID, types.ContainerStartOptions{})
+	if err != nil {
+		logger.Error("error al iniciar el contenedor", "error", err)
+		tracing.RecordError(startSpan, err)
+		startSpan.End()
+		nc.Publish(msg.Reply, []byte("Error al iniciar el contenedor: "+err.Error()))
+		return
+	}
+	startSpan.End()
+	metrics.ContainerStarts.WithLabelValues(req.Function.Name, startKind).Inc()
+	metrics.RunningContainers.Inc()
+	usage := models.UsageRecord{
+		ExecutionID:   req.ContainerId,
+		Function:      req.Function.Name,
+		User:          req.Function.OwnerId,
+		Start:         start,
+		MemoryLimitMB: memoryMB,
+		ExitCode:      -1,
+	}
+	defer func() {
+		metrics.RunningContainers.Dec()
+		usage.Duration = time.Since(start)
+		usage.Status = "error"
+		if usage.ExitCode == 0 {
+			usage.Status = "success"
+		} else if ctx.Err() == context.DeadlineExceeded {
+			usage.Status = "timeout"
+		}
+		execution.ExitCode = usage.ExitCode
+		execution.Status = usage.Status
+		metrics.ContainerDuration.WithLabelValues(req.Function.Name).Observe(usage.Duration.Seconds())
+		metrics.WorkerInvocations.WithLabelValues(req.Function.Name, usage.Status).Inc()
+		logger.Info("ejecución terminada", "status", usage.Status, "exit_code", usage.ExitCode,
+			"duration_ms", usage.Duration.Milliseconds(), "start", startKind)
+		if err := metering.PublishUsage(wk.js, usage); err != nil {
+			logger.Error("error al publicar el uso", "error", err)
+		}
+	}()
+	logOpts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	}
+
+	logReader, err := dockerClient.ContainerLogs(ctx, resp.ID, logOpts)
+	if err != nil {
+		logger.Error("error al leer la salida del contenedor", "error", err)
+		nc.Publish(msg.Reply, []byte(err.Error()))
+		return
+	}
+
+	// The output is also stored in the LOGS stream, line by line
+	shipReader, shipWriter := io.Pipe()
+	shipped := make(chan struct{})
+	go func() {
+		defer close(shipped)
+		shipLogs(shipReader, wk.logRepository, req.Function, req.ContainerId, logger)
+	}()
+	defer func() {
+		select {
+		case <-shipped:
+		case <-time.After(5 * time.Second):
+			logger.Warn("tiempo agotado al guardar los logs de la función")
+		}
+		if err := wk.logRepository.Flush(5 * time.Second); err != nil {
+			logger.Error("error al confirmar los logs de la función", "error", err)
+		}
+	}()
+
+	logCh := make(chan []byte, 1)
+	go func() {
+		defer close(logCh)
+		defer logReader.Close()
+		_, err := io.Copy(io.MultiWriter(channelWriter{logCh}, shipWriter), logReader)
+		shipWriter.CloseWithError(err)
+	}()
+
+	_, waitSpan := tracing.Start(ctx, "container.wait")
+	defer func() {
+		waitSpan.SetAttributes(attribute.Int64("faas.exit_code", usage.ExitCode))
+		waitSpan.End()
+	}()
+	statusCh, errCh := dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+
+	select {
+	case logs := <-logCh:
+		logger.Debug("logs del contenedor", "output", string(logs))
+		nc.Publish(msg.Reply, logs)
+		// Wait for the exit code for the usage record
+		select {
+		case status := <-statusCh:
+			usage.ExitCode = status.StatusCode
+		case err := <-errCh:
+			logger.Error("error al esperar a que el contenedor termine", "error", err)
+		}
+	case err := <-errCh:
+		logger.Error("error al esperar a que el contenedor termine", "error", err)
+		if wk.ctx.Err() != nil {
+			nc.Publish(msg.Reply, []byte("Ejecución interrumpida: el worker se está deteniendo"))
+		} else {
+			nc.Publish(msg.Reply, []byte(err.Error()))
+		}
+
+	case status := <-statusCh:
+		usage.ExitCode = status.StatusCode
+		// The container may exit before its output is read; the channel
+		// is closed without data if there was none
+		select {
+		case logs := <-logCh:
+			nc.Publish(msg.Reply, logs)
+		case <-time.After(5 * time.Second):
+			nc.Publish(msg.Reply, nil)
+		}
+	}
+
+	// A timed out or interrupted container would keep running on its own
+	if ctx.Err() != nil {
+		killCtx, cancelKill := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelKill()
+		if err := dockerClient.ContainerKill(killCtx, resp.ID, "KILL"); err != nil && !client.IsErrNotFound(err) {
+			logger.Error("error al detener el contenedor", "error", err)
+		}
+	}
+}
+
+// imageDigest returns the registry digest of the pulled image: the one in
+// the reference if it is pinned, otherwise the one the registry served for
+// its repository.
+func imageDigest(image types.ImageInspect, reference string) string {
+	parsed, err := imagepolicy.Parse(reference)
+	if err != nil {
+		return ""
+	}
+	if parsed.Digest != "" {
+		return parsed.Digest
+	}
+	for _, repoDigest := range image.RepoDigests {
+		name, digest, ok := strings.Cut(repoDigest, "@")
+		if !ok {
+			continue
+		}
+		if repo, err := imagepolicy.Parse(name); err == nil && repo.Registry == parsed.Registry && repo.Repository == parsed.Repository {
+			return digest
+		}
+	}
+	return ""
+}
+
+// requeue hands a message received while draining back to the queue group,
+// so another worker runs it.
+func (wk *worker) requeue(msg *nats.Msg) {
+	requeued := &nats.Msg{
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Header:  msg.Header,
+		Data:    msg.Data,
+	}
+	if err := wk.nc.PublishMsg(requeued); err != nil {
+		wk.logger.Error("error al devolver la ejecución a la cola", "subject", msg.Subject, "error", err)
+		wk.nc.Publish(msg.Reply, []byte("Ejecución rechazada: el worker se está deteniendo"))
+	}
+}
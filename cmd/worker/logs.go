@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"time"
+
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// maxLogLine splits longer lines so each fits in a LOGS message.
+const maxLogLine = 16 * 1024
+
+// lineWriter turns one container stream into log entries, one per line.
+type lineWriter struct {
+	repository *repository.NATSLogRepository
+	entry      models.LogEntry
+	logger     *slog.Logger
+	buf        []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 && len(lw.buf) < maxLogLine {
+			return len(p), nil
+		}
+		if i < 0 || i > maxLogLine {
+			i = maxLogLine
+			lw.emit(lw.buf[:i])
+			lw.buf = lw.buf[i:]
+			continue
+		}
+		lw.emit(lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+}
+
+func (lw *lineWriter) emit(line []byte) {
+	entry := lw.entry
+	entry.Time = time.Now().UTC()
+	entry.Line = string(bytes.TrimSuffix(line, []byte("\r")))
+	if err := lw.repository.Append(entry); err != nil {
+		lw.logger.Error("error al guardar el log de la función", "error", err)
+	}
+}
+
+// flush emits the last line if the output did not end with a newline.
+func (lw *lineWriter) flush() {
+	if len(lw.buf) > 0 {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+}
+
+// shipLogs demultiplexes the Docker log stream read from r into stdout and
+// stderr entries of the LOGS stream. It returns once r is closed.
+func shipLogs(r io.Reader, logRepository *repository.NATSLogRepository, function models.Function, executionID string, logger *slog.Logger) {
+	entry := models.LogEntry{Namespace: function.OwnerId, Function: function.Name, ExecutionID: executionID}
+	stdout := &lineWriter{repository: logRepository, entry: entry, logger: logger}
+	stdout.entry.Stream = "stdout"
+	stderr := &lineWriter{repository: logRepository, entry: entry, logger: logger}
+	stderr.entry.Stream = "stderr"
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, r); err != nil {
+		logger.Warn("error al separar la salida del contenedor", "error", err)
+		// Keep draining so the container log reader is not blocked
+		io.Copy(io.Discard, r)
+	}
+	stdout.flush()
+	stderr.flush()
+}
@@ -2,133 +2,140 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"faas-project/internal/models"
+	"faas-project/internal/health"
+	"faas-project/internal/logging"
+	"faas-project/internal/metrics"
+	"faas-project/internal/repository"
+	"faas-project/internal/sandbox"
+	"faas-project/internal/tracing"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/nats-io/nats.go"
 )
 
 var url = "nats://nats:4222"
 
-type channelWriter struct {
-	channel chan []byte
-}
-
-func (cw channelWriter) Write(p []byte) (n int, err error) {
-	cw.channel <- p
-	return len(p), nil
-}
-
 func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	workerID, _ := os.Hostname()
+	logger := logging.Init("worker").With("worker_id", workerID)
+
 	nc, err := nats.Connect(url)
 
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("error al conectar con NATS", "error", err)
+		os.Exit(1)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		logger.Error("error al obtener el contexto de JetStream", "error", err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "worker")
+	if err != nil {
+		logger.Error("error al iniciar las trazas", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		logger.Error("error al crear el cliente de Docker", "error", err)
+		os.Exit(1)
+	}
+	defer dockerClient.Close()
+
+	wk := &worker{
+		ctx:                 ctx,
+		id:                  workerID,
+		nc:                  nc,
+		js:                  js,
+		docker:              dockerClient,
+		logger:              logger,
+		sandboxPolicy:       sandbox.LoadPolicy(),
+		policyRepository:    repository.NewNATSPolicyRepository(js),
+		logRepository:       repository.NewNATSLogRepository(js),
+		executionRepository: repository.NewNATSExecutionRepository(js),
+		workerRepository:    repository.NewNATSWorkerRepository(js),
+	}
+	if err := wk.sandboxPolicy.EnsureEgressNetwork(context.Background(), dockerClient); err != nil {
+		logger.Error("error al crear la red de salida", "network", wk.sandboxPolicy.EgressNetwork, "error", err)
 	}
 
-	nc.QueueSubscribe(
-		"functions.*", "workers",
-		func(msg *nats.Msg) {
-
-			dockerClient, err := client.NewClientWithOpts(client.FromEnv)
-			if err != nil {
-				log.Printf("Error al crear el cliente de Docker: %v", err)
-				return
-			}
-
-			var req struct {
-				Function    models.Function `json:"function"`
-				Param       string          `json:"param"`
-				ContainerId string          `json:"containerId"`
-			}
-			if err := json.Unmarshal(msg.Data, &req); err != nil {
-				log.Printf("Error al deserializar la solicitud de ejecución: %v", err)
-				return
-			}
-
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			hostConfig := &container.HostConfig{
-				AutoRemove:  true,
-				NetworkMode: container.NetworkMode("faas-project_faas-network"),
-			}
-			reader, err := dockerClient.ImagePull(ctx, req.Function.Image, types.ImagePullOptions{})
-			if err != nil {
-				log.Printf("No se ha encontrado la imagen en docker.io: %v", err)
-				return
-			}
-
-			_, err = io.Copy(os.Stdout, reader)
-			if err != nil {
-				log.Printf("Error al copiar la salida del pull: %v", err)
-				return
-			}
-			resp, err := dockerClient.ContainerCreate(ctx, &container.Config{
-				Image:        req.Function.Image,
-				Env:          []string{fmt.Sprintf("PARAM=%s", req.Param)},
-				Tty:          false,
-				AttachStdout: true,
-				AttachStderr: true,
-			}, hostConfig, nil, nil, req.ContainerId)
-			if err != nil {
-				log.Printf("Error al crear el contenedor: %v", err)
-				return
-			}
-			err = dockerClient.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
-			if err != nil {
-				log.Printf("Error al iniciar el contenedor: %v", err)
-				return
-			}
-			logOpts := types.ContainerLogsOptions{
-				ShowStdout: true,
-				ShowStderr: true,
-				Follow:     true,
-			}
-
-			logReader, err := dockerClient.ContainerLogs(ctx, resp.ID, logOpts)
-			if err != nil {
-				return
-			}
-
-			logCh := make(chan []byte, 1)
-			go func() {
-				defer close(logCh)
-				_, err := io.Copy(channelWriter{logCh}, logReader)
-				if err != nil {
-					return
-				}
-			}()
-
-			statusCh, errCh := dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-
-			select {
-			case logs := <-logCh:
-				log.Printf("Logs del contenedor: %s", logs)
-				nc.Publish(msg.Reply, logs)
-			case err := <-errCh:
-				log.Printf("Error al esperar a que el contenedor termine: %v", err)
-				nc.Publish(msg.Reply, []byte(err.Error()))
-
-			case status := <-statusCh:
-				log.Printf("Estado del contenedor: %d", status.StatusCode)
-			}
-
-		})
+	// Registered before subscribing, so no other reaper takes our
+	// containers for orphans
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go wk.heartbeatLoop(background)
+	go wk.reapLoop(background, dockerClient)
+
+	sub, err := nc.QueueSubscribe("functions.*", "workers", wk.handle)
+	if err != nil {
+		logger.Error("error al suscribirse a las ejecuciones", "error", err)
+		os.Exit(1)
+	}
+
+	metrics.RegisterWorker(nc, func() float64 {
+		pending, _, _ := sub.Pending()
+		return float64(pending)
+	})
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = ":9091"
+	}
+	// The metrics listener also serves the health probes
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.HandleFunc("/healthz", health.Handler(health.NATS(nc)))
+		mux.HandleFunc("/readyz", health.Handler(health.Draining(&wk.draining), health.NATS(nc), health.Subscription(sub), health.Docker(dockerClient)))
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			logger.Error("error en el servidor de métricas", "error", err)
+		}
+	}()
+
+	logger.Info("worker iniciado")
 	<-sigChan
 
+	// Stop taking messages: the subscription is drained and whatever is
+	// still pending is requeued for other workers. The running invocation
+	// gets until the deadline to finish.
+	timeout := health.ShutdownTimeout(25 * time.Second)
+	logger.Info("deteniendo el worker", "timeout", timeout.String())
+	wk.draining.Store(true)
+	closed := sub.StatusChanged(nats.SubscriptionClosed)
+	if err := sub.Drain(); err != nil {
+		logger.Error("error al drenar la suscripción", "error", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(timeout):
+		logger.Warn("tiempo de parada agotado, interrumpiendo las ejecuciones en curso")
+		cancel()
+		select {
+		case <-closed:
+		case <-time.After(10 * time.Second):
+		}
+	}
+	stopBackground()
+	if err := wk.workerRepository.Remove(wk.id); err != nil {
+		logger.Error("error al dar de baja el worker", "error", err)
+	}
+	if err := nc.Drain(); err != nil {
+		logger.Error("error al drenar la conexión con NATS", "error", err)
+	}
+	logger.Info("worker detenido")
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"faas-project/internal/models"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Labels set on every function container, read back by the reaper.
+const (
+	labelNamespace   = "faas.namespace"
+	labelFunction    = "faas.function"
+	labelExecution   = "faas.execution"
+	labelWorker      = "faas.worker"
+	labelCaller      = "faas.caller"
+	labelParamDigest = "faas.param-digest"
+	labelStarted     = "faas.started"
+	labelDeadline    = "faas.deadline"
+	labelVersion     = "faas.version"
+)
+
+const (
+	heartbeatInterval = 10 * time.Second
+	// reapGrace leaves the owner time to kill a container itself once its
+	// deadline has passed
+	reapGrace = 30 * time.Second
+)
+
+func reapInterval() time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv("REAPER_INTERVAL")); err == nil && interval > 0 {
+		return interval
+	}
+	return time.Minute
+}
+
+// heartbeatLoop keeps the worker registered as alive until ctx ends.
+func (wk *worker) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		if err := wk.workerRepository.Heartbeat(wk.id); err != nil {
+			wk.logger.Error("error al registrar el worker", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapLoop runs the reaper on startup and then periodically until ctx ends.
+func (wk *worker) reapLoop(ctx context.Context, dockerClient *client.Client) {
+	ticker := time.NewTicker(reapInterval())
+	defer ticker.Stop()
+	for {
+		wk.reap(ctx, dockerClient)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap kills function containers nobody will collect: those of this worker
+// left over from a previous run, those of workers that stopped sending
+// heartbeats and those well past their deadline. Their executions are
+// recorded as failed.
+func (wk *worker) reap(ctx context.Context, dockerClient *client.Client) {
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelExecution)),
+	})
+	if err != nil {
+		wk.logger.Error("error al listar los contenedores de funciones", "error", err)
+		return
+	}
+
+	aliveWorkers := map[string]bool{}
+	for _, c := range containers {
+		executionID := c.Labels[labelExecution]
+		owner := c.Labels[labelWorker]
+
+		reason := ""
+		if owner == wk.id {
+			if _, running := wk.running.Load(executionID); !running {
+				reason = "worker reiniciado"
+			}
+		} else {
+			alive, checked := aliveWorkers[owner]
+			if !checked {
+				// If the registry cannot be read the owner is assumed alive
+				var err error
+				if alive, err = wk.workerRepository.Alive(owner); err != nil {
+					wk.logger.Warn("error al consultar el registro de workers", "worker", owner, "error", err)
+					alive = true
+				}
+				aliveWorkers[owner] = alive
+			}
+			if !alive {
+				reason = "worker caído"
+			}
+		}
+		if deadline, err := time.Parse(time.RFC3339, c.Labels[labelDeadline]); reason == "" && err == nil && time.Now().After(deadline.Add(reapGrace)) {
+			reason = "plazo superado"
+		}
+		if reason == "" {
+			continue
+		}
+
+		logger := wk.logger.With("container", c.ID, "execution_id", executionID, "function", c.Labels[labelFunction], "owner_worker", owner)
+		logger.Warn("eliminando contenedor huérfano", "reason", reason)
+		if err := dockerClient.ContainerKill(ctx, c.ID, "KILL"); err != nil && !client.IsErrNotFound(err) {
+			logger.Error("error al detener el contenedor huérfano", "error", err)
+			continue
+		}
+		// AutoRemove may not be set on containers from older versions
+		dockerClient.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true})
+
+		execution := models.Execution{
+			ID:          executionID,
+			Namespace:   c.Labels[labelNamespace],
+			Function:    c.Labels[labelFunction],
+			Image:       c.Image,
+			ImageID:     c.ImageID,
+			Caller:      c.Labels[labelCaller],
+			ParamDigest: c.Labels[labelParamDigest],
+			End:         time.Now().UTC(),
+			ExitCode:    -1,
+			Status:      "error",
+			WorkerID:    owner,
+		}
+		if version, err := strconv.ParseInt(c.Labels[labelVersion], 10, 64); err == nil {
+			execution.Version = version
+		}
+		if started, err := time.Parse(time.RFC3339Nano, c.Labels[labelStarted]); err == nil {
+			execution.Start = started
+			execution.Duration = execution.End.Sub(started)
+		}
+		if err := wk.executionRepository.Record(execution); err != nil {
+			logger.Error("error al marcar la ejecución como fallida", "error", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package apierror writes the error body shared by every endpoint of the
+// API:
+//
+//	{"status": "error", "code": "not_found", "message": "..."}
+//
+// code is stable and meant for programs; message is for people and may
+// change.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error is the body of every error response.
+type Error struct {
+	Status  string `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Generic codes, used when there is no more specific one.
+const (
+	BadRequest           = "bad_request"
+	Unauthorized         = "unauthorized"
+	Forbidden            = "forbidden"
+	NotFound             = "not_found"
+	MethodNotAllowed     = "method_not_allowed"
+	NotAcceptable        = "not_acceptable"
+	Conflict             = "conflict"
+	PayloadTooLarge      = "payload_too_large"
+	UnsupportedMediaType = "unsupported_media_type"
+	TooManyRequests      = "too_many_requests"
+	Internal             = "internal_error"
+	Unavailable          = "unavailable"
+	Timeout              = "timeout"
+)
+
+// CodeFor returns the generic code of an HTTP status.
+func CodeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return BadRequest
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusNotAcceptable:
+		return NotAcceptable
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusRequestEntityTooLarge:
+		return PayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return UnsupportedMediaType
+	case http.StatusTooManyRequests:
+		return TooManyRequests
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return Timeout
+	}
+	return Internal
+}
+
+// Write sends an error response.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Status: "error", Code: code, Message: message})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+	"io"
+	"net/http"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ApplyHandler reconciles the caller's namespace to a faas.yaml file, with
+// the same validation as POST /function. It answers with the changes made,
+// or only computed with ?dryRun=true; ?prune=true deletes the functions the
+// file does not list.
+func (h *Handlers) ApplyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	namespace, ok := h.targetNamespace(w, r)
+	if !ok {
+		return
+	}
+	file, err := readFaasFile(w, r)
+	if err != nil {
+		setErrorFrom(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	specs := make([]models.FunctionSpec, 0, len(file.Functions))
+	for _, definition := range file.Functions {
+		specs = append(specs, definition.Spec())
+	}
+	h.reconcile(w, r, namespace, specs, nil)
+}
+
+// readFaasFile decodes a faas.yaml file. Unknown fields are rejected so a
+// typo does not silently drop part of the definition.
+func readFaasFile(w http.ResponseWriter, r *http.Request) (models.FaasFile, error) {
+	var file models.FaasFile
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxManifestBytes))
+	if err != nil {
+		return file, i18n.New("faasfile_too_large", maxManifestBytes)
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return file, i18n.New("faasfile_invalid", err)
+	}
+	if file.APIVersion != models.FaasFileVersion {
+		return file, i18n.New("faasfile_version_unsupported", file.APIVersion, models.FaasFileVersion)
+	}
+	return file, nil
+}
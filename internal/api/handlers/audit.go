@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditHandler lists audit events for admins, filtered by user, function
+// and time range (RFC 3339). /audit/export and ?format=jsonl return the
+// events as JSON lines.
+func (h *Handlers) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:    query.Get("user"),
+		Function: query.Get("function"),
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			setError(w, r, http.StatusBadRequest, "since_invalid")
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			setError(w, r, http.StatusBadRequest, "until_invalid")
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			setError(w, r, http.StatusBadRequest, "limit_invalid")
+			return
+		}
+	}
+
+	events, err := repository.GetAuditRepository().Query(filter)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "audit_query_failed")
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/export") || query.Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			encoder.Encode(event)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
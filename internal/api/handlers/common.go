@@ -1,10 +1,64 @@
 package handlers
 
 import (
+	"encoding/json"
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/i18n"
+	"faas-project/internal/repository"
 	"fmt"
 	"net/http"
 )
 
+// Handlers serves the API on top of the repositories it is built with, so
+// the storage backend is chosen by main and not by the handlers.
+type Handlers struct {
+	functions repository.FunctionRepository
+	users     repository.UserRepository
+	invoker   repository.FunctionInvoker
+}
+
+// New builds the handlers. Invocations always go through invoker, whatever
+// backend stores the functions.
+func New(functions repository.FunctionRepository, users repository.UserRepository, invoker repository.FunctionInvoker) *Handlers {
+	return &Handlers{functions: functions, users: users, invoker: invoker}
+}
+
 func DefaultHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello, World!")
 }
+
+// setSuccess writes the {"status", "code", "message"} body of a successful
+// operation, with the message of code in the caller's language.
+func setSuccess(w http.ResponseWriter, r *http.Request, status int, code string, args ...any) {
+	message := i18n.Message(language(w, r), code, args...)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"code":    code,
+		"message": message,
+	})
+}
+
+// setError writes an error response with the message of code in the
+// caller's language.
+func setError(w http.ResponseWriter, r *http.Request, status int, code string, args ...any) {
+	apierror.Write(w, status, code, i18n.Message(language(w, r), code, args...))
+}
+
+// setErrorFrom writes err with its own code, or with the generic code of
+// status if it has none.
+func setErrorFrom(w http.ResponseWriter, r *http.Request, status int, err error) {
+	code, ok := i18n.Code(err)
+	if !ok {
+		code = apierror.CodeFor(status)
+	}
+	apierror.Write(w, status, code, i18n.Translate(language(w, r), err))
+}
+
+// language returns the language of the response to r and announces it in
+// the Content-Language header.
+func language(w http.ResponseWriter, r *http.Request) string {
+	lang := i18n.FromRequest(r)
+	w.Header().Set("Content-Language", lang)
+	return lang
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultExecutionLimit = 50
+	maxExecutionLimit     = 500
+	maxStatsBuckets       = 500
+)
+
+// FunctionExecutionsHandler lists the invocation history of a function,
+// filtered by caller, status and start time and paginated with the cursor
+// returned in "next".
+func (h *Handlers) FunctionExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	function, ok := h.ownedFunction(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.ExecutionFilter{
+		Namespace: function.OwnerId,
+		Function:  function.Name,
+		Caller:    query.Get("caller"),
+		Status:    query.Get("status"),
+		Limit:     defaultExecutionLimit,
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = parseSince(since); err != nil {
+			setErrorFrom(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			setError(w, r, http.StatusBadRequest, "until_invalid")
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 || filter.Limit > maxExecutionLimit {
+			setError(w, r, http.StatusBadRequest, "limit_out_of_range", maxExecutionLimit)
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.After, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			setError(w, r, http.StatusBadRequest, "cursor_invalid")
+			return
+		}
+	}
+
+	executions, next, err := repository.GetExecutionRepository().Query(filter)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "history_query_failed")
+		return
+	}
+	response := map[string]interface{}{
+		"executions": executions,
+	}
+	if next > 0 {
+		response["next"] = strconv.FormatUint(next, 10)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// FunctionStatsHandler returns invocation counts, error rate and latency
+// percentiles of a function over a window (24h by default), also split in
+// intervals if "interval" is given.
+func (h *Handlers) FunctionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	function, ok := h.ownedFunction(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	window := 24 * time.Hour
+	if value := query.Get("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			setError(w, r, http.StatusBadRequest, "window_invalid")
+			return
+		}
+		window = d
+	}
+	interval := window
+	if value := query.Get("interval"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 || d > window || window/d > maxStatsBuckets {
+			setError(w, r, http.StatusBadRequest, "interval_invalid", maxStatsBuckets)
+			return
+		}
+		interval = d
+	}
+
+	end := time.Now().UTC()
+	start := end.Add(-window)
+	executions, _, err := repository.GetExecutionRepository().Query(models.ExecutionFilter{
+		Namespace: function.OwnerId,
+		Function:  function.Name,
+		Since:     start,
+	})
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "history_query_failed")
+		return
+	}
+
+	response := map[string]interface{}{
+		"function": function.Name,
+		"window":   executionStats(executions, start, end),
+	}
+	if query.Get("interval") != "" {
+		buckets := []models.ExecutionStats{}
+		for bucketStart := start; bucketStart.Before(end); bucketStart = bucketStart.Add(interval) {
+			bucketEnd := bucketStart.Add(interval)
+			if bucketEnd.After(end) {
+				bucketEnd = end
+			}
+			buckets = append(buckets, executionStats(executions, bucketStart, bucketEnd))
+		}
+		response["intervals"] = buckets
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// executionStats aggregates the executions started in [start, end).
+func executionStats(executions []models.Execution, start, end time.Time) models.ExecutionStats {
+	stats := models.ExecutionStats{Start: start, End: end}
+	durations := []time.Duration{}
+	for _, execution := range executions {
+		if execution.Start.Before(start) || !execution.Start.Before(end) {
+			continue
+		}
+		stats.Invocations++
+		switch execution.Status {
+		case "success":
+		case "timeout":
+			stats.Timeouts++
+			stats.Errors++
+		default:
+			stats.Errors++
+		}
+		durations = append(durations, execution.Duration)
+	}
+	if stats.Invocations == 0 {
+		return stats
+	}
+	stats.ErrorRate = float64(stats.Errors) / float64(stats.Invocations)
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	stats.P50Ms = percentile(durations, 0.50).Milliseconds()
+	stats.P95Ms = percentile(durations, 0.95).Milliseconds()
+	stats.P99Ms = percentile(durations, 0.99).Milliseconds()
+	return stats
+}
+
+// percentile uses the nearest-rank method on sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"faas-project/internal/models"
+)
+
+func durations(ms ...int) []time.Duration {
+	sorted := make([]time.Duration, 0, len(ms))
+	for _, m := range ms {
+		sorted = append(sorted, time.Duration(m)*time.Millisecond)
+	}
+	return sorted
+}
+
+func TestPercentile(t *testing.T) {
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"single p50", durations(7), 0.50, 7 * time.Millisecond},
+		{"single p99", durations(7), 0.99, 7 * time.Millisecond},
+		{"two p50", durations(1, 2), 0.50, time.Millisecond},
+		{"two p95", durations(1, 2), 0.95, 2 * time.Millisecond},
+		{"odd p50", durations(1, 2, 3, 4, 5), 0.50, 3 * time.Millisecond},
+		{"hundred p50", durations(hundred...), 0.50, 50 * time.Millisecond},
+		{"hundred p95", durations(hundred...), 0.95, 95 * time.Millisecond},
+		{"hundred p99", durations(hundred...), 0.99, 99 * time.Millisecond},
+		{"p0", durations(1, 2, 3), 0, time.Millisecond},
+		{"p100", durations(1, 2, 3), 1, 3 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := percentile(test.sorted, test.p); got != test.want {
+				t.Errorf("percentile(%v, %v) = %v, want %v", test.sorted, test.p, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecutionStats(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	executions := []models.Execution{
+		{Start: start, Duration: 30 * time.Millisecond, Status: "success"},
+		{Start: start.Add(time.Minute), Duration: 10 * time.Millisecond, Status: "error"},
+		{Start: start.Add(2 * time.Minute), Duration: 20 * time.Millisecond, Status: "timeout"},
+		{Start: start.Add(3 * time.Minute), Duration: 40 * time.Millisecond, Status: "success"},
+		// outside [start, end)
+		{Start: start.Add(-time.Second), Duration: time.Second, Status: "error"},
+		{Start: end, Duration: time.Second, Status: "error"},
+	}
+
+	stats := executionStats(executions, start, end)
+	want := models.ExecutionStats{
+		Start: start, End: end,
+		Invocations: 4, Errors: 2, Timeouts: 1, ErrorRate: 0.5,
+		P50Ms: 20, P95Ms: 40, P99Ms: 40,
+	}
+	if stats != want {
+		t.Errorf("executionStats() = %+v, want %+v", stats, want)
+	}
+
+	empty := executionStats(nil, start, end)
+	if empty != (models.ExecutionStats{Start: start, End: end}) {
+		t.Errorf("executionStats(nil) = %+v, want only the window", empty)
+	}
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"faas-project/internal/i18n"
+	"faas-project/internal/imagepolicy"
+	"faas-project/internal/metering"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const maxManifestBytes = 4 << 20
+
+// ExportHandler returns the functions of the caller's namespace as a
+// manifest, in JSON or, with ?format=yaml or a YAML Accept header, in YAML.
+// Admins can export another namespace with ?namespace=.
+func (h *Handlers) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	namespace, ok := h.targetNamespace(w, r)
+	if !ok {
+		return
+	}
+	functions, err := h.functions.GetFunctionsByUser(namespace)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "function_list_failed")
+		return
+	}
+
+	manifest := models.Manifest{
+		Version:    models.ManifestVersion,
+		Namespace:  namespace,
+		ExportedAt: time.Now().UTC(),
+		Functions:  []models.FunctionSpec{},
+	}
+	for _, function := range functions {
+		// env values may hold secrets, so only their names are exported
+		secretEnv := []string{}
+		for name := range function.Env {
+			secretEnv = append(secretEnv, name)
+		}
+		sort.Strings(secretEnv)
+		manifest.Functions = append(manifest.Functions, models.FunctionSpec{
+			Name:      function.Name,
+			Version:   function.Version,
+			Image:     function.Image,
+			MemoryMB:  function.MemoryMB,
+			SecretEnv: secretEnv,
+			Labels:    function.Labels,
+			Security:  function.Security,
+		})
+	}
+	manifest.RegistryCredentials = credentialRegistries(namespace, manifest.Functions)
+
+	if wantsYAML(r) {
+		data, err := yaml.Marshal(manifest)
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "manifest_generate_failed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// ImportHandler applies a manifest (JSON or YAML) to the caller's
+// namespace. Functions missing from the namespace are created and those
+// that differ are updated; with ?prune=true the functions not in the
+// manifest are deleted. ?dryRun=true only reports the changes.
+func (h *Handlers) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	namespace, ok := h.targetNamespace(w, r)
+	if !ok {
+		return
+	}
+	manifest, err := readManifest(w, r)
+	if err != nil {
+		setErrorFrom(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	h.reconcile(w, r, namespace, manifest.Functions, manifest.RegistryCredentials)
+}
+
+// reconcile brings namespace to specs and writes the result. It honours
+// the ?prune= and ?dryRun= options of /import and /apply.
+func (h *Handlers) reconcile(w http.ResponseWriter, r *http.Request, namespace string, specs []models.FunctionSpec, registries []string) {
+	query := r.URL.Query()
+	steps, status, err := h.planChanges(namespace, specs, query.Get("prune") == "true")
+	if err != nil {
+		setErrorFrom(w, r, status, err)
+		return
+	}
+	result := models.ImportResult{
+		Namespace:          namespace,
+		DryRun:             query.Get("dryRun") == "true",
+		MissingCredentials: missingCredentials(namespace, registries),
+	}
+	for _, step := range steps {
+		result.MissingEnv = append(result.MissingEnv, step.missingEnv...)
+	}
+
+	var ok bool
+	status = http.StatusOK
+	if result.DryRun {
+		result.Changes = planned(steps)
+	} else if result.Changes, ok = h.applyChanges(r.Context(), steps); !ok {
+		status = http.StatusInternalServerError
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// targetNamespace returns the namespace a request works on: the caller's
+// or, for admins, the one given in ?namespace=.
+func (h *Handlers) targetNamespace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return "", false
+	}
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" || namespace == userName {
+		return userName, true
+	}
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return "", false
+	}
+	return namespace, true
+}
+
+func wantsYAML(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "yaml" || strings.Contains(r.Header.Get("Accept"), "yaml")
+}
+
+// readManifest decodes a JSON or YAML manifest and checks its version.
+func readManifest(w http.ResponseWriter, r *http.Request) (models.Manifest, error) {
+	var manifest models.Manifest
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxManifestBytes))
+	if err != nil {
+		return manifest, i18n.New("manifest_too_large", maxManifestBytes)
+	}
+	// YAML is a superset of JSON, so both are accepted
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return manifest, i18n.New("manifest_invalid", err)
+	}
+	if manifest.Version == 0 {
+		return manifest, i18n.New("manifest_version_required")
+	}
+	if manifest.Version > models.ManifestVersion {
+		return manifest, i18n.New("manifest_version_unsupported", manifest.Version, models.ManifestVersion)
+	}
+	return manifest, nil
+}
+
+// credentialRegistries lists the registries of the images the namespace
+// has credentials for. Only the registry names are exported, never the
+// credentials themselves.
+func credentialRegistries(namespace string, specs []models.FunctionSpec) []string {
+	seen := map[string]bool{}
+	registries := []string{}
+	for _, spec := range specs {
+		image, err := imagepolicy.Parse(spec.Image)
+		if err != nil || seen[image.Registry] {
+			continue
+		}
+		seen[image.Registry] = true
+		if _, err := repository.GetPolicyRepository().GetRegistryCredential(namespace, image.Registry); err == nil {
+			registries = append(registries, image.Registry)
+		}
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// missingCredentials returns the registries a manifest needs credentials
+// for that the namespace does not have yet.
+func missingCredentials(namespace string, registries []string) []string {
+	var missing []string
+	for _, registry := range registries {
+		if _, err := repository.GetPolicyRepository().GetRegistryCredential(namespace, registry); err != nil {
+			missing = append(missing, registry)
+		}
+	}
+	return missing
+}
+
+// planStep is one change of a plan together with the definition it writes
+// and the secretEnv variables it could not fill in.
+type planStep struct {
+	change     models.ManifestChange
+	function   models.Function
+	missingEnv []string
+}
+
+// planChanges compares specs with the functions stored in namespace. Every
+// spec is validated like a registration and the function quota is checked
+// against the result, so a plan that fails here changes nothing.
+func (h *Handlers) planChanges(namespace string, specs []models.FunctionSpec, prune bool) ([]planStep, int, error) {
+	stored, err := h.functions.GetFunctionsByUser(namespace)
+	if err != nil {
+		return nil, http.StatusInternalServerError, i18n.New("function_list_failed")
+	}
+	existing := map[string]models.Function{}
+	for _, function := range stored {
+		existing[function.Name] = function
+	}
+
+	steps := []planStep{}
+	seen := map[string]bool{}
+	creates, deletes := 0, 0
+	for _, spec := range specs {
+		if seen[spec.Name] {
+			return nil, http.StatusBadRequest, i18n.New("manifest_duplicate_function", spec.Name)
+		}
+		seen[spec.Name] = true
+		current, exists := existing[spec.Name]
+		env, missingEnv := resolveSecretEnv(spec, current.Env)
+		function := models.Function{
+			Name:     spec.Name,
+			OwnerId:  namespace,
+			Image:    spec.Image,
+			MemoryMB: spec.MemoryMB,
+			Env:      env,
+			Labels:   spec.Labels,
+			Security: spec.Security,
+		}
+		if status, err := validateFunction(function); err != nil {
+			return nil, status, i18n.New("manifest_function_invalid", spec.Name, err)
+		}
+		for _, name := range spec.SecretEnv {
+			if !envNamePattern.MatchString(name) || reservedEnv[name] {
+				return nil, http.StatusBadRequest, i18n.New("manifest_function_invalid", spec.Name, i18n.New("function_env_invalid", name))
+			}
+		}
+
+		step := planStep{change: models.ManifestChange{Function: spec.Name}, function: function, missingEnv: missingEnv}
+		if !exists {
+			step.change.Action = models.ChangeCreate
+			stamp(&step.function, nil)
+			creates++
+		} else {
+			step.function.ID = current.ID
+			step.change.Fields = changedFields(current, function)
+			step.change.Action = models.ChangeUpdate
+			if len(step.change.Fields) == 0 {
+				step.change.Action = models.ChangeUnchanged
+			}
+			stamp(&step.function, &current)
+		}
+		steps = append(steps, step)
+	}
+	if prune {
+		for _, function := range stored {
+			if !seen[function.Name] {
+				steps = append(steps, planStep{
+					change:   models.ManifestChange{Action: models.ChangeDelete, Function: function.Name},
+					function: function,
+				})
+				deletes++
+			}
+		}
+	}
+
+	if quota := metering.LoadQuota(); quota.MaxFunctions > 0 && creates > 0 && len(stored)+creates-deletes > quota.MaxFunctions {
+		return nil, http.StatusForbidden, i18n.New("function_quota_reached", quota.MaxFunctions)
+	}
+	return steps, http.StatusOK, nil
+}
+
+// resolveSecretEnv returns the env of spec with the values of its secretEnv
+// variables taken from current, and the variables current has no value for.
+func resolveSecretEnv(spec models.FunctionSpec, current map[string]string) (map[string]string, []string) {
+	if len(spec.SecretEnv) == 0 {
+		return spec.Env, nil
+	}
+	env := map[string]string{}
+	for name, value := range spec.Env {
+		env[name] = value
+	}
+	var missing []string
+	for _, name := range spec.SecretEnv {
+		if _, ok := env[name]; ok {
+			continue
+		}
+		if value, ok := current[name]; ok {
+			env[name] = value
+		} else {
+			missing = append(missing, spec.Name+"/"+name)
+		}
+	}
+	return env, missing
+}
+
+// changedFields lists the fields of a function definition that differ.
+func changedFields(current, desired models.Function) []string {
+	fields := []string{}
+	if current.Image != desired.Image {
+		fields = append(fields, "image")
+	}
+	if current.MemoryMB != desired.MemoryMB {
+		fields = append(fields, "memoryMB")
+	}
+	if (len(current.Env) > 0 || len(desired.Env) > 0) && !reflect.DeepEqual(current.Env, desired.Env) {
+		fields = append(fields, "env")
+	}
+	if (len(current.Labels) > 0 || len(desired.Labels) > 0) && !reflect.DeepEqual(current.Labels, desired.Labels) {
+		fields = append(fields, "labels")
+	}
+	if !reflect.DeepEqual(current.Security, desired.Security) {
+		fields = append(fields, "security")
+	}
+	return fields
+}
+
+func planned(steps []planStep) []models.ManifestChange {
+	changes := make([]models.ManifestChange, 0, len(steps))
+	for _, step := range steps {
+		changes = append(changes, step.change)
+	}
+	return changes
+}
+
+// applyChanges runs the steps in order. A failed step is reported in its
+// change and does not stop the rest; the result is false if any failed.
+func (h *Handlers) applyChanges(ctx context.Context, steps []planStep) ([]models.ManifestChange, bool) {
+	ok := true
+	changes := make([]models.ManifestChange, 0, len(steps))
+	for _, step := range steps {
+		var err error
+		switch step.change.Action {
+		case models.ChangeCreate:
+			err = h.functions.CreateFunction(step.function)
+		case models.ChangeUpdate:
+			err = h.functions.Update(step.function)
+		case models.ChangeDelete:
+			if err = h.functions.DeleteFunction(step.function); err == nil {
+				purgeFunctionData(ctx, step.function)
+			}
+		}
+		if err != nil {
+			step.change.Error = err.Error()
+			ok = false
+		}
+		changes = append(changes, step.change)
+	}
+	return changes, ok
+}
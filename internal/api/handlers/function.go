@@ -1,118 +1,225 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"faas-project/internal/api/router"
+	"faas-project/internal/i18n"
+	"faas-project/internal/imagepolicy"
+	"faas-project/internal/logging"
+	"faas-project/internal/metering"
+	"faas-project/internal/middleware"
 	"faas-project/internal/models"
 	"faas-project/internal/repository"
+	"faas-project/internal/sandbox"
+	"faas-project/internal/tracing"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v4"
+	"time"
 )
 
-func RegisterFunctionHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) RegisterFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	var function models.Function
 	w.Header().Set("Content-Type", "application/json")
 
 	err := json.NewDecoder(r.Body).Decode(&function)
 	if err != nil {
-		setResponse(w, http.StatusBadRequest, "error", err.Error())
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
 		return
 	}
 
-	if function.Name == "" || function.Image == "" {
-		setResponse(w, http.StatusBadRequest, "error", "Nombre e imagen son requeridos")
+	if status, err := validateFunction(function); err != nil {
+		setErrorFrom(w, r, status, err)
 		return
 	}
-	existingFunction, err := repository.GetFunctionRepository().GetFunctionsByUser(function.OwnerId)
+	authHeader := r.Header.Get("Authorization")
+	userName, err := extractUserFromToken(authHeader)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al obtener funciones del usuario")
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
 		return
 	}
-	for _, f := range existingFunction {
-		if f.Name == function.Name {
-			setResponse(w, http.StatusConflict, "error", "Ya existe una función con ese nombre")
-			return
-		}
+	if userName != function.OwnerId {
+		setError(w, r, http.StatusForbidden, "function_forbidden")
+		return
 	}
-	authHeader := r.Header.Get("Authorization")
-	userName, err := extractUserFromToken(authHeader)
+	existingFunction, err := h.functions.GetFunctionsByUser(function.OwnerId)
 	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Token inválido")
+		setError(w, r, http.StatusInternalServerError, "function_list_failed")
 		return
 	}
-	if userName != function.OwnerId {
-		setResponse(w, http.StatusForbidden, "error", "No tienes permisos para ejecutar esta función")
+	if quota := metering.LoadQuota(); quota.MaxFunctions > 0 && len(existingFunction) >= quota.MaxFunctions {
+		setError(w, r, http.StatusForbidden, "function_quota_reached", quota.MaxFunctions)
+		return
+	}
+	stamp(&function, nil)
+	err = h.functions.CreateFunction(function)
+	if err == repository.ErrFunctionExists {
+		setError(w, r, http.StatusConflict, "function_exists")
+		return
+	}
+	if err == repository.ErrConflict {
+		setError(w, r, http.StatusServiceUnavailable, "concurrent_changes")
 		return
 	}
-	err = repository.GetFunctionRepository().CreateFunction(function)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al registrar la función")
+		setError(w, r, http.StatusInternalServerError, "function_register_failed")
 		return
 	}
-	setResponse(w, http.StatusCreated, "success", "Función registrada exitosamente")
+	setSuccess(w, r, http.StatusCreated, "function_registered")
+}
+
+var (
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// Label keys and values follow the Kubernetes syntax, without prefixes
+	labelKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// reservedEnv are the variables the worker sets on every container.
+var reservedEnv = map[string]bool{"PARAM": true, "TRACEPARENT": true, "TRACESTATE": true}
+
+// validateFunction checks a function definition against the sandbox and
+// image policies and returns the status to reject it with.
+func validateFunction(function models.Function) (int, error) {
+	if function.Name == "" || function.Image == "" {
+		return http.StatusBadRequest, i18n.New("function_fields_required")
+	}
+	if _, err := sandbox.LoadPolicy().Resolve(function.Security); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if _, err := sandbox.LoadPolicy().MemoryLimit(function.MemoryMB); err != nil {
+		return http.StatusBadRequest, err
+	}
+	for name := range function.Env {
+		if !envNamePattern.MatchString(name) || reservedEnv[name] {
+			return http.StatusBadRequest, i18n.New("function_env_invalid", name)
+		}
+	}
+	for key, value := range function.Labels {
+		if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+			return http.StatusBadRequest, i18n.New("function_label_invalid", key+"="+value)
+		}
+	}
+	return checkImagePolicy(function.Image)
+}
+
+// stamp sets the version and timestamps of a definition about to be stored
+// over current, or as a new function if current is nil.
+func stamp(function *models.Function, current *models.Function) {
+	now := time.Now().UTC()
+	function.Version = 1
+	function.CreatedAt = now
+	function.UpdatedAt = now
+	if current != nil {
+		function.Version = current.Version + 1
+		function.CreatedAt = current.CreatedAt
+	}
 }
 
-func DeleteFunctionHandler(w http.ResponseWriter, r *http.Request) {
+// GetFunctionHandler returns the definition of one of the caller's
+// functions together with its image digest and last invocation.
+func (h *Handlers) GetFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	functionName := strings.TrimPrefix(r.URL.Path, "/function/")
-	if functionName == "" {
-		setResponse(w, http.StatusBadRequest, "error", "Nombre de función requerido")
+	function, ok := h.ownedFunction(w, r)
+	if !ok {
 		return
 	}
-	function, err := repository.GetFunctionRepository().GetFunctionByName(functionName)
-	if err != nil {
-		setResponse(w, http.StatusNotFound, "error", "Función no encontrada para este usuario")
+	status := models.FunctionStatus{Function: function}
+	if image, err := imagepolicy.Parse(function.Image); err == nil {
+		status.ImageDigest = image.Digest
+	}
+	last, err := repository.GetExecutionRepository().Last(function.OwnerId, function.Name)
+	if err != nil && err != repository.ErrExecutionNotFound {
+		setError(w, r, http.StatusInternalServerError, "history_query_failed")
+		return
+	}
+	if err == nil {
+		status.LastInvocation = &last
+		// Digests of runs of a previous image do not describe this one
+		if status.ImageDigest == "" && last.Image == function.Image {
+			status.ImageDigest = last.ImageDigest
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *Handlers) DeleteFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	functionName := router.Param(r, "name")
+	if functionName == "" {
+		setError(w, r, http.StatusBadRequest, "function_name_required")
 		return
 	}
 	authHeader := r.Header.Get("Authorization")
 	userName, err := extractUserFromToken(authHeader)
 	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Token inválido")
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
 		return
 	}
-	if userName != function.OwnerId {
-		setResponse(w, http.StatusForbidden, "error", "No tienes permisos para eliminar esta función")
+	// Functions are looked up in the caller's namespace
+	function, err := h.functions.GetFunction(userName, functionName)
+	if err != nil {
+		setError(w, r, http.StatusNotFound, "function_not_found")
+		return
+	}
+	err = h.functions.DeleteFunction(function)
+	if err == repository.ErrFunctionNotFound {
+		setError(w, r, http.StatusNotFound, "function_not_found")
+		return
+	}
+	if err == repository.ErrConflict {
+		setError(w, r, http.StatusServiceUnavailable, "concurrent_changes")
 		return
 	}
-	err = repository.GetFunctionRepository().DeleteFunction(function)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al eliminar la función")
+		setError(w, r, http.StatusInternalServerError, "function_delete_failed")
 		return
 	}
+	purgeFunctionData(r.Context(), function)
+
+	setSuccess(w, r, http.StatusOK, "function_deleted")
+}
 
-	setResponse(w, http.StatusOK, "success", "Función eliminada exitosamente")
+// purgeFunctionData drops the logs and execution history of a deleted
+// function. Failures are only logged: the function is already gone.
+func purgeFunctionData(ctx context.Context, function models.Function) {
+	if err := repository.GetLogRepository().Purge(function.OwnerId, function.Name); err != nil {
+		logging.FromContext(ctx).Error("error al borrar los logs de la función", "function", function.Name, "error", err)
+	}
+	if err := repository.GetExecutionRepository().Purge(function.OwnerId, function.Name); err != nil {
+		logging.FromContext(ctx).Error("error al borrar el historial de la función", "function", function.Name, "error", err)
+	}
 }
 
-func ExecuteFunctionHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) ExecuteFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodPost {
-		setResponse(w, http.StatusMethodNotAllowed, "error", "Método no permitido")
-		return
-	}
+	ctx, span := tracing.Start(tracing.ExtractHTTP(r.Context(), r.Header), "ExecuteFunctionHandler")
+	defer span.End()
 
-	functionName := strings.TrimPrefix(r.URL.Path, "/function/")
+	functionName := router.Param(r, "name")
 	if functionName == "" {
-		setResponse(w, http.StatusBadRequest, "error", "Nombre de función requerido")
+		setError(w, r, http.StatusBadRequest, "function_name_required")
 		return
 	}
 
-	function, err := repository.GetFunctionRepository().GetFunctionByName(functionName)
+	authHeader := r.Header.Get("Authorization")
+	userName, err := extractUserFromToken(authHeader)
 	if err != nil {
-		setResponse(w, http.StatusNotFound, "error", "Función no encontrada para este usuario")
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
 		return
 	}
-	authHeader := r.Header.Get("Authorization")
-	userName, err := extractUserFromToken(authHeader)
+	function, err := h.functions.GetFunction(userName, functionName)
 	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Token inválido")
+		setError(w, r, http.StatusNotFound, "function_not_found")
 		return
 	}
-	if userName != function.OwnerId {
-		setResponse(w, http.StatusForbidden, "error", "No tienes permisos para ejecutar esta función")
+	if !checkInvocationQuota(w, r, userName) {
 		return
 	}
 
@@ -121,35 +228,11 @@ func ExecuteFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	err = json.NewDecoder(r.Body).Decode(&param)
 	if err != nil {
-		setResponse(w, http.StatusBadRequest, "error", "Error al decodificar el parámetro")
+		setError(w, r, http.StatusBadRequest, "param_invalid")
 		return
 	}
-	repository.GetFunctionRepository().PublishFunction(function, param.Param, w)
-}
-
-func GetFunctionsByUserHandler(w http.ResponseWriter, r *http.Request) {
-	username := r.URL.Query().Get("username")
-	if username == "" {
-		setResponse(w, http.StatusBadRequest, "error", "Nombre de usuario requerido")
-		return
-	}
-	function, err := repository.GetFunctionRepository().GetFunctionsByUser(username)
-	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al obtener funciones del usuario")
-		return
-	}
-	authHeader := r.Header.Get("Authorization")
-	checkedUser, err := extractUserFromToken(authHeader)
-	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Token inválido")
-		return
-	}
-	if checkedUser != username {
-		setResponse(w, http.StatusForbidden, "error", "No tienes permisos para acceder a estas funciones")
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(function)
+	span.SetAttributes(tracing.FunctionAttributes(function.Name, "", userName)...)
+	h.invoker.PublishFunction(i18n.WithLanguage(ctx, language(w, r)), function, userName, param.Param, w)
 }
 
 func extractUserFromToken(tokenString string) (string, error) {
@@ -158,21 +241,9 @@ func extractUserFromToken(tokenString string) (string, error) {
 	} else {
 		return "", fmt.Errorf("token inválido")
 	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
-		}
-		return []byte("kohi"), nil
-	})
+	username, err := middleware.ParseToken(tokenString)
 	if err != nil {
 		return "", fmt.Errorf("token inválido: %v", err)
 	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if username, ok := claims["sub"].(string); ok {
-			return username, nil
-		}
-	}
-
-	return "", fmt.Errorf("token inválido o expirado")
+	return username, nil
 }
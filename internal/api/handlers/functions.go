@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxFunctionLimit = 1000
+
+// functionSorts are the orders accepted in ?sort=; a leading "-" reverses
+// them. Ties are broken by name.
+var functionSorts = map[string]func(a, b models.Function) int{
+	"name":      func(a, b models.Function) int { return strings.Compare(a.Name, b.Name) },
+	"createdAt": func(a, b models.Function) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updatedAt": func(a, b models.Function) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// GetFunctionsByUserHandler lists the functions of the token's user,
+// filtered by ?label=key or ?label=key=value (repeatable, all must match),
+// sorted by ?sort= and paginated with ?limit= and ?cursor=. The body is the
+// array of functions; the total and the next cursor go in the X-Total-Count
+// and X-Next-Cursor headers. ?username= is still accepted but must be the
+// token's user.
+func (h *Handlers) GetFunctionsByUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return
+	}
+	query := r.URL.Query()
+	if username := query.Get("username"); username != "" && username != userName {
+		setError(w, r, http.StatusForbidden, "functions_forbidden")
+		return
+	}
+
+	selector := map[string]*string{}
+	for _, label := range query["label"] {
+		key, value, hasValue := strings.Cut(label, "=")
+		if !labelKeyPattern.MatchString(key) {
+			setError(w, r, http.StatusBadRequest, "label_selector_invalid", label)
+			return
+		}
+		selector[key] = nil
+		if hasValue {
+			selector[key] = &value
+		}
+	}
+	order := query.Get("sort")
+	if order == "" {
+		order = "name"
+	}
+	descending := strings.HasPrefix(order, "-")
+	compare, ok := functionSorts[strings.TrimPrefix(order, "-")]
+	if !ok {
+		setError(w, r, http.StatusBadRequest, "sort_invalid", order)
+		return
+	}
+	limit, offset := 0, 0
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxFunctionLimit {
+			setError(w, r, http.StatusBadRequest, "limit_out_of_range", maxFunctionLimit)
+			return
+		}
+	}
+	if value := query.Get("cursor"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			setError(w, r, http.StatusBadRequest, "cursor_invalid")
+			return
+		}
+	}
+
+	stored, err := h.functions.GetFunctionsByUser(userName)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "function_list_failed")
+		return
+	}
+	functions := []models.Function{}
+	for _, function := range stored {
+		if matchesLabels(function, selector) {
+			functions = append(functions, function)
+		}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		c := compare(functions[i], functions[j])
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			return functions[i].Name < functions[j].Name
+		}
+		return c < 0
+	})
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(functions)))
+	end := len(functions)
+	if offset > end {
+		offset = end
+	}
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+		w.Header().Set("X-Next-Cursor", strconv.Itoa(end))
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(functions[offset:end])
+}
+
+// matchesLabels reports whether function has every label of selector; a
+// nil value only requires the key.
+func matchesLabels(function models.Function, selector map[string]*string) bool {
+	for key, value := range selector {
+		label, ok := function.Labels[key]
+		if !ok || (value != nil && label != *value) {
+			return false
+		}
+	}
+	return true
+}
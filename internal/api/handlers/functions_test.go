@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestGetFunctionsByUserHandler(t *testing.T) {
+	functions := repository.NewMemoryFunctionRepository()
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, function := range []models.Function{
+		{Name: "c", Labels: map[string]string{"team": "web", "tier": "front"}},
+		{Name: "a", Labels: map[string]string{"team": "web"}},
+		{Name: "b", Labels: map[string]string{"team": "data"}},
+		{Name: "d"},
+	} {
+		function.OwnerId = "alice"
+		function.Image = "alpine"
+		function.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		if err := functions.CreateFunction(function); err != nil {
+			t.Fatal(err)
+		}
+	}
+	functions.CreateFunction(models.Function{Name: "other", OwnerId: "bob", Image: "alpine"})
+	h := New(functions, repository.NewMemoryUserRepository(), nil)
+
+	token, err := issueToken("alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		query  string
+		token  string
+		status int
+		names  []string
+		total  string
+		next   string
+	}{
+		{name: "all by name", status: http.StatusOK, names: []string{"a", "b", "c", "d"}, total: "4"},
+		{name: "descending", query: "sort=-name", status: http.StatusOK, names: []string{"d", "c", "b", "a"}, total: "4"},
+		{name: "by creation", query: "sort=createdAt", status: http.StatusOK, names: []string{"c", "a", "b", "d"}, total: "4"},
+		{name: "label key", query: "label=team", status: http.StatusOK, names: []string{"a", "b", "c"}, total: "3"},
+		{name: "label value", query: "label=team=web", status: http.StatusOK, names: []string{"a", "c"}, total: "2"},
+		{name: "every label", query: "label=team=web&label=tier", status: http.StatusOK, names: []string{"c"}, total: "1"},
+		{name: "first page", query: "limit=3", status: http.StatusOK, names: []string{"a", "b", "c"}, total: "4", next: "3"},
+		{name: "last page", query: "limit=3&cursor=3", status: http.StatusOK, names: []string{"d"}, total: "4"},
+		{name: "past the end", query: "cursor=10", status: http.StatusOK, names: []string{}, total: "4"},
+		{name: "own username", query: "username=alice", status: http.StatusOK, names: []string{"a", "b", "c", "d"}, total: "4"},
+		{name: "other username", query: "username=bob", status: http.StatusForbidden},
+		{name: "invalid label", query: "label=-bad", status: http.StatusBadRequest},
+		{name: "invalid sort", query: "sort=image", status: http.StatusBadRequest},
+		{name: "limit too high", query: "limit=1001", status: http.StatusBadRequest},
+		{name: "invalid cursor", query: "cursor=-1", status: http.StatusBadRequest},
+		{name: "invalid token", token: "nope", status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/functions?"+test.query, nil)
+			if test.token == "" {
+				test.token = token
+			}
+			r.Header.Set("Authorization", "Bearer "+test.token)
+			w := httptest.NewRecorder()
+			h.GetFunctionsByUserHandler(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var listed []models.Function
+			if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, function := range listed {
+				names = append(names, function.Name)
+			}
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf("functions = %v, want %v", names, test.names)
+			}
+			if total := w.Header().Get("X-Total-Count"); total != test.total {
+				t.Errorf("X-Total-Count = %q, want %q", total, test.total)
+			}
+			if next := w.Header().Get("X-Next-Cursor"); next != test.next {
+				t.Errorf("X-Next-Cursor = %q, want %q", next, test.next)
+			}
+		})
+	}
+}
+
+// runNats starts an embedded NATS server with JetStream and sets it up as
+// the API does, for the handlers that read the platform configuration.
+func runNats(t *testing.T) {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatal("el servidor NATS no arrancó")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	if err := message.InitNats(nc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterFunctionQuotaAfterOwnership(t *testing.T) {
+	runNats(t)
+	t.Setenv("QUOTA_MAX_FUNCTIONS", "1")
+	functions := repository.NewMemoryFunctionRepository()
+	if err := functions.CreateFunction(models.Function{Name: "a", OwnerId: "bob", Image: "alpine"}); err != nil {
+		t.Fatal(err)
+	}
+	h := New(functions, repository.NewMemoryUserRepository(), nil)
+
+	tests := []struct {
+		name   string
+		user   string
+		status int
+		code   string
+	}{
+		{name: "other owner", user: "alice", status: http.StatusForbidden, code: "function_forbidden"},
+		{name: "quota reached", user: "bob", status: http.StatusForbidden, code: "function_quota_reached"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := issueToken(test.user, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/function", strings.NewReader(`{"name":"b","ownerId":"bob","image":"alpine"}`))
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			h.RegisterFunctionHandler(w, r)
+
+			var body apierror.Error
+			json.NewDecoder(w.Body).Decode(&body)
+			if w.Code != test.status || body.Code != test.code {
+				t.Errorf("response = %d %q, want %d %q", w.Code, body.Code, test.status, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/api/router"
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLogLimit = 1000
+	maxLogLimit     = 10000
+)
+
+var stopStreams = make(chan struct{})
+
+// StopStreams ends the open log streams when the server shuts down.
+func StopStreams() {
+	close(stopStreams)
+}
+
+// parseSince accepts an RFC 3339 date or a duration relative to now ("10m").
+func parseSince(value string) (time.Time, error) {
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, i18n.New("since_invalid")
+	}
+	return time.Now().Add(-d), nil
+}
+
+// ownedFunction resolves the function of a /function/{name}/... route
+// in the caller's namespace, writing the error response otherwise.
+func (h *Handlers) ownedFunction(w http.ResponseWriter, r *http.Request) (models.Function, bool) {
+	functionName := router.Param(r, "name")
+	if functionName == "" {
+		setError(w, r, http.StatusBadRequest, "function_name_required")
+		return models.Function{}, false
+	}
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return models.Function{}, false
+	}
+	function, err := h.functions.GetFunction(userName, functionName)
+	if err != nil {
+		setError(w, r, http.StatusNotFound, "function_not_found")
+		return models.Function{}, false
+	}
+	return function, true
+}
+
+// FunctionLogsHandler returns the stored output of a function. With
+// follow=true the logs are streamed as Server-Sent Events until the client
+// disconnects.
+func (h *Handlers) FunctionLogsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	function, ok := h.ownedFunction(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.LogFilter{
+		Namespace:   function.OwnerId,
+		Function:    function.Name,
+		ExecutionID: query.Get("execution"),
+		Limit:       defaultLogLimit,
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = parseSince(since); err != nil {
+			setErrorFrom(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 || filter.Limit > maxLogLimit {
+			setError(w, r, http.StatusBadRequest, "limit_out_of_range", maxLogLimit)
+			return
+		}
+	}
+
+	if query.Get("follow") == "true" {
+		followLogs(w, r, filter)
+		return
+	}
+	entries, err := repository.GetLogRepository().Query(filter)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "logs_query_failed")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+func followLogs(w http.ResponseWriter, r *http.Request, filter models.LogFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		setError(w, r, http.StatusInternalServerError, "streaming_unsupported")
+		return
+	}
+	entries, err := repository.GetLogRepository().Follow(r.Context(), filter)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "logs_query_failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Comments keep proxies from closing an idle stream
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-stopStreams:
+			return
+		}
+	}
+}
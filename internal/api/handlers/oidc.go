@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"faas-project/internal/auth"
+	"faas-project/internal/i18n"
+	"faas-project/internal/logging"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// OIDCLoginHandler starts the authorization code flow with PKCE and redirects
+// the browser to the identity provider.
+func (h *Handlers) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !auth.OIDCEnabled() {
+		setError(w, r, http.StatusNotFound, "oidc_disabled")
+		return
+	}
+	state, err := randomString(32)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "oidc_start_failed")
+		return
+	}
+	codeVerifier, err := randomString(64)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "oidc_start_failed")
+		return
+	}
+	err = repository.GetOIDCSessionRepository().Save(state, codeVerifier)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "oidc_start_failed")
+		return
+	}
+	http.Redirect(w, r, auth.GetOIDCProvider().AuthorizationURL(state, codeVerifier), http.StatusFound)
+}
+
+// OIDCCallbackHandler redeems the authorization code, maps the ID token claims
+// to a local user and returns a platform token like LoginHandler does.
+func (h *Handlers) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !auth.OIDCEnabled() {
+		setError(w, r, http.StatusNotFound, "oidc_disabled")
+		return
+	}
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		setError(w, r, http.StatusUnauthorized, "oidc_rejected", idpError)
+		return
+	}
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		setError(w, r, http.StatusBadRequest, "oidc_params_required")
+		return
+	}
+	codeVerifier, err := repository.GetOIDCSessionRepository().Take(state)
+	if err != nil {
+		setError(w, r, http.StatusBadRequest, "oidc_state_invalid")
+		return
+	}
+	identity, err := auth.GetOIDCProvider().Exchange(code, codeVerifier)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("error al validar el login OIDC", "error", err)
+		setError(w, r, http.StatusUnauthorized, "invalid_credentials")
+		return
+	}
+
+	user, err := h.users.GetByUsername(identity.Username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		user = models.User{
+			Username:    identity.Username,
+			CreatedAt:   time.Now(),
+			OIDCIssuer:  identity.Issuer,
+			OIDCSubject: identity.Subject,
+		}
+		err = h.users.CreateUser(user)
+		if errors.Is(err, repository.ErrUserExists) {
+			// Created by a concurrent login
+			user, err = h.users.GetByUsername(identity.Username)
+		}
+	}
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "user_create_failed")
+		return
+	}
+	// Never log an IdP subject into an account it is not linked to
+	if user.Password != "" || user.OIDCIssuer != identity.Issuer || user.OIDCSubject != identity.Subject {
+		logging.FromContext(r.Context()).Warn("login OIDC sobre una cuenta no vinculada", "user", identity.Username, "subject", identity.Subject)
+		setError(w, r, http.StatusConflict, "oidc_account_conflict")
+		return
+	}
+
+	if admin, managed := auth.GetOIDCProvider().AdminRole(identity); managed && user.HasRole("admin") != admin {
+		err := h.users.UpdateUser(identity.Username, func(stored *models.User) error {
+			stored.Roles = withRole(stored.Roles, "admin", admin)
+			return nil
+		})
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "user_update_failed")
+			return
+		}
+		logging.FromContext(r.Context()).Info("rol de administrador sincronizado con los equipos OIDC", "user", identity.Username, "admin", admin)
+	}
+
+	tokenString, err := issueToken(identity.Username, identity.Teams)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "token_issue_failed")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"code":    "logged_in",
+		"message": i18n.Message(language(w, r), "logged_in"),
+		"token":   tokenString,
+	})
+}
+
+// withRole returns roles with role added or removed.
+func withRole(roles []string, role string, present bool) []string {
+	roles = slices.DeleteFunc(slices.Clone(roles), func(r string) bool { return r == role })
+	if present {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/i18n"
+	"faas-project/internal/imagepolicy"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"net/http"
+)
+
+// ImagePolicyHandler returns (GET) or replaces (PUT) the platform image
+// policy. Admin only.
+func (h *Handlers) ImagePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := repository.GetPolicyRepository().GetImagePolicy()
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "image_policy_read_failed")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(policy)
+	case http.MethodPut:
+		var policy models.ImagePolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			setError(w, r, http.StatusBadRequest, "invalid_body", err)
+			return
+		}
+		for _, denied := range policy.DeniedImages {
+			if denied == "" {
+				setError(w, r, http.StatusBadRequest, "denied_image_empty")
+				return
+			}
+		}
+		if err := repository.GetPolicyRepository().SaveImagePolicy(policy); err != nil {
+			setError(w, r, http.StatusInternalServerError, "image_policy_save_failed")
+			return
+		}
+		setSuccess(w, r, http.StatusOK, "image_policy_updated")
+	default:
+		setError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+	}
+}
+
+// RegistryCredentialHandler stores (PUT) or removes (DELETE) the pull
+// credential of a private registry for the caller's namespace. Admins may
+// set the namespace explicitly. Passwords are never returned.
+func (h *Handlers) RegistryCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return
+	}
+	var credential models.RegistryCredential
+	if err := json.NewDecoder(r.Body).Decode(&credential); err != nil {
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
+		return
+	}
+	if credential.Registry == "" {
+		setError(w, r, http.StatusBadRequest, "registry_required")
+		return
+	}
+	if credential.Namespace == "" {
+		credential.Namespace = userName
+	}
+	if credential.Namespace != userName {
+		if _, ok := h.requireAdmin(w, r); !ok {
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if credential.Username == "" || credential.Password == "" {
+			setError(w, r, http.StatusBadRequest, "registry_credentials_required")
+			return
+		}
+		if err := repository.GetPolicyRepository().SaveRegistryCredential(credential); err != nil {
+			setError(w, r, http.StatusInternalServerError, "registry_credentials_save_failed")
+			return
+		}
+		setSuccess(w, r, http.StatusOK, "registry_credentials_saved")
+	case http.MethodDelete:
+		err := repository.GetPolicyRepository().DeleteRegistryCredential(credential.Namespace, credential.Registry)
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "registry_credentials_delete_failed")
+			return
+		}
+		setSuccess(w, r, http.StatusOK, "registry_credentials_deleted")
+	default:
+		setError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+	}
+}
+
+// checkImagePolicy returns the status and reason to reject the image with
+// if it is not allowed by the platform policy.
+func checkImagePolicy(image string) (int, error) {
+	policy, err := repository.GetPolicyRepository().GetImagePolicy()
+	if err != nil {
+		return http.StatusInternalServerError, i18n.New("image_policy_read_failed")
+	}
+	if err := imagepolicy.Check(policy, image); err != nil {
+		return http.StatusForbidden, i18n.New("image_rejected", err)
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"faas-project/internal/metering"
+	"faas-project/internal/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UsageHandler returns the caller's current consumption and quota.
+func (h *Handlers) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return
+	}
+	now := time.Now()
+	today, err := repository.GetUsageRepository().GetDay(userName, now)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "usage_read_failed")
+		return
+	}
+	month, err := repository.GetUsageRepository().GetMonth(userName, now)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "usage_read_failed")
+		return
+	}
+	functions, err := h.functions.GetFunctionsByUser(userName)
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "function_list_failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":      userName,
+		"functions": len(functions),
+		"today":     today,
+		"month":     month,
+		"quota":     metering.LoadQuota(),
+	})
+}
+
+// checkInvocationQuota writes the error response and returns false if the
+// user has exhausted its daily invocations or monthly GB-seconds.
+func checkInvocationQuota(w http.ResponseWriter, r *http.Request, userName string) bool {
+	quota := metering.LoadQuota()
+	if quota.MaxInvocationsPerDay == 0 && quota.MaxGBSecondsPerMonth == 0 {
+		return true
+	}
+	now := time.Now()
+	if quota.MaxInvocationsPerDay > 0 {
+		today, err := repository.GetUsageRepository().GetDay(userName, now)
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "quota_check_failed")
+			return false
+		}
+		if today.Invocations >= quota.MaxInvocationsPerDay {
+			tomorrow := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(tomorrow).Seconds())+1))
+			setError(w, r, http.StatusTooManyRequests, "daily_quota_exhausted", quota.MaxInvocationsPerDay)
+			return false
+		}
+	}
+	if quota.MaxGBSecondsPerMonth > 0 {
+		month, err := repository.GetUsageRepository().GetMonth(userName, now)
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "quota_check_failed")
+			return false
+		}
+		if month.GBSeconds >= quota.MaxGBSecondsPerMonth {
+			setError(w, r, http.StatusForbidden, "monthly_quota_exhausted", quota.MaxGBSecondsPerMonth)
+			return false
+		}
+	}
+	return true
+}
@@ -1,92 +1,332 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"faas-project/internal/auth"
+	"faas-project/internal/i18n"
+	"faas-project/internal/logging"
 	"faas-project/internal/middleware"
 	"faas-project/internal/models"
 	"faas-project/internal/repository"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	w.Header().Set("Content-Type", "application/json")
 
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		setResponse(w, http.StatusBadRequest, "error", err.Error())
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
 		return
 	}
 
-	storedUser, err := repository.GetUserRepository().GetByUsername(user.Username)
+	attemptRepository := repository.GetLoginAttemptRepository()
+	userKey := repository.UserAttemptKey(user.Username)
+	ipKey := repository.IPAttemptKey(middleware.ClientIP(r))
+	for _, key := range []string{userKey, ipKey} {
+		attempts, err := attemptRepository.Get(key)
+		if err != nil {
+			setError(w, r, http.StatusInternalServerError, "login_attempts_failed")
+			return
+		}
+		if time.Now().Before(attempts.LockedUntil) {
+			setLockedResponse(w, r, attempts.LockedUntil)
+			return
+		}
+	}
+
+	storedUser, err := h.users.GetByUsername(user.Username)
 	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Credenciales inválidas")
+		h.recordLoginFailure(r.Context(), userKey, ipKey, nil)
+		setError(w, r, http.StatusUnauthorized, "invalid_credentials")
+		return
+	}
+	if time.Now().Before(storedUser.LockedUntil) {
+		setLockedResponse(w, r, storedUser.LockedUntil)
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password))
 	if err != nil {
-		setResponse(w, http.StatusUnauthorized, "error", "Credenciales inválidas")
+		h.recordLoginFailure(r.Context(), userKey, ipKey, &storedUser)
+		setError(w, r, http.StatusUnauthorized, "invalid_credentials")
 		return
 	}
+	// Only the username counter is reset: resetting the IP one would let an
+	// attacker with a valid account keep guessing other passwords.
+	attemptRepository.Reset(userKey)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": storedUser.Username,
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
-	})
-	tokenString, err := token.SignedString(middleware.JwtSecret)
+	tokenString, err := issueToken(storedUser.Username, nil)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "No se pudo generar el token")
+		setError(w, r, http.StatusInternalServerError, "token_issue_failed")
 		return
 	}
 
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":  "success",
-		"message": "Usuario logueado correctamente",
+		"code":    "logged_in",
+		"message": i18n.Message(language(w, r), "logged_in"),
 		"token":   tokenString,
 	})
 }
 
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	w.Header().Set("Content-Type", "application/json")
 
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		setResponse(w, http.StatusBadRequest, "error", err.Error())
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
+		return
+	}
+	if user.Username == "" {
+		setError(w, r, http.StatusBadRequest, "username_required")
+		return
+	}
+	if strings.HasPrefix(user.Username, auth.OIDCUserPrefix) {
+		setError(w, r, http.StatusBadRequest, "username_reserved", auth.OIDCUserPrefix)
+		return
+	}
+	if err := auth.LoadPasswordPolicy().Validate(user.Password); err != nil {
+		setErrorFrom(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	// Verificar si el usuario existe
-	existingUser, err := repository.GetUserRepository().GetByUsername(user.Username)
-	if err == nil && existingUser.Password != "" {
-		setResponse(w, http.StatusConflict, "error", "El usuario ya existe")
+	// Verificar si el usuario existe (los usuarios creados por OIDC no tienen contraseña)
+	existingUser, err := h.users.GetByUsername(user.Username)
+	if err == nil && existingUser.Username != "" {
+		setError(w, r, http.StatusConflict, "user_exists")
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al procesar el registro")
+		setError(w, r, http.StatusInternalServerError, "registration_failed")
+		return
+	}
+	// Roles and lock state are never taken from the request body
+	newUser := models.User{
+		ID:        user.ID,
+		Username:  user.Username,
+		Password:  string(hashedPassword),
+		CreatedAt: time.Now(),
+	}
+	if isBootstrapAdmin(user.Username) {
+		newUser.Roles = []string{"admin"}
+	}
+	err = h.users.CreateUser(newUser)
+	if errors.Is(err, repository.ErrUserExists) {
+		setError(w, r, http.StatusConflict, "user_exists")
 		return
 	}
-	user.Password = string(hashedPassword)
-	err = repository.GetUserRepository().CreateUser(user)
 	if err != nil {
-		setResponse(w, http.StatusInternalServerError, "error", "Error al crear el usuario")
+		setError(w, r, http.StatusInternalServerError, "user_create_failed")
 		return
 	}
 
-	setResponse(w, http.StatusCreated, "success", "Usuario registrado correctamente")
+	setSuccess(w, r, http.StatusCreated, "user_registered")
 }
 
-func setResponse(w http.ResponseWriter, status int, statusMessage string, content string) {
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  statusMessage,
-		"message": content,
+func (h *Handlers) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return
+	}
+	var body struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
+		return
+	}
+
+	storedUser, err := h.users.GetByUsername(userName)
+	if err != nil {
+		setError(w, r, http.StatusNotFound, "user_not_found")
+		return
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(body.CurrentPassword))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "invalid_credentials")
+		return
+	}
+	if err := h.setPassword(storedUser, body.NewPassword); err != nil {
+		setPasswordError(w, r, err)
+		return
+	}
+	setSuccess(w, r, http.StatusOK, "password_changed")
+}
+
+// AdminResetPasswordHandler lets an admin set a new password for any user and
+// clears its lockout.
+func (h *Handlers) AdminResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+	var body struct {
+		Username    string `json:"username"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
+		return
+	}
+
+	storedUser, err := h.users.GetByUsername(body.Username)
+	if err != nil {
+		setError(w, r, http.StatusNotFound, "user_not_found")
+		return
+	}
+	if err := h.setPassword(storedUser, body.NewPassword); err != nil {
+		setPasswordError(w, r, err)
+		return
+	}
+	repository.GetLoginAttemptRepository().Reset(repository.UserAttemptKey(body.Username))
+	setSuccess(w, r, http.StatusOK, "password_reset")
+}
+
+// AdminSetPlanHandler assigns a rate limit plan to a user. Admin only.
+func (h *Handlers) AdminSetPlanHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+	var body struct {
+		Username string `json:"username"`
+		Plan     string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		setError(w, r, http.StatusBadRequest, "invalid_body", err)
+		return
+	}
+	if _, ok := middleware.LoadRateLimitPlans()[body.Plan]; !ok && body.Plan != "" {
+		setError(w, r, http.StatusBadRequest, "plan_unknown", body.Plan)
+		return
+	}
+
+	err := h.users.UpdateUser(body.Username, func(user *models.User) error {
+		user.Plan = body.Plan
+		return nil
 	})
+	if errors.Is(err, repository.ErrUserNotFound) {
+		setError(w, r, http.StatusNotFound, "user_not_found")
+		return
+	}
+	if err != nil {
+		setError(w, r, http.StatusInternalServerError, "user_update_failed")
+		return
+	}
+	setSuccess(w, r, http.StatusOK, "plan_updated")
+}
+
+type passwordPolicyError struct{ error }
+
+func (h *Handlers) setPassword(user models.User, password string) error {
+	if err := auth.LoadPasswordPolicy().Validate(password); err != nil {
+		return passwordPolicyError{err}
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return h.users.UpdateUser(user.Username, func(user *models.User) error {
+		user.Password = string(hashedPassword)
+		user.LockedUntil = time.Time{}
+		return nil
+	})
+}
+
+func setPasswordError(w http.ResponseWriter, r *http.Request, err error) {
+	if policyErr, ok := err.(passwordPolicyError); ok {
+		setErrorFrom(w, r, http.StatusBadRequest, policyErr.error)
+		return
+	}
+	setError(w, r, http.StatusInternalServerError, "password_update_failed")
+}
+
+func (h *Handlers) recordLoginFailure(ctx context.Context, userKey, ipKey string, user *models.User) {
+	logger := logging.FromContext(ctx)
+	lockoutPolicy := auth.LoadLockoutPolicy()
+	attemptRepository := repository.GetLoginAttemptRepository()
+
+	if _, err := attemptRepository.RecordFailure(ipKey, lockoutPolicy.LockoutFor); err != nil {
+		logger.Error("error al registrar el intento fallido", "key", ipKey, "error", err)
+	}
+	attempts, err := attemptRepository.RecordFailure(userKey, lockoutPolicy.LockoutFor)
+	if err != nil {
+		logger.Error("error al registrar el intento fallido", "key", userKey, "error", err)
+		return
+	}
+	if user != nil && attempts.LockedUntil.After(user.LockedUntil) {
+		err := h.users.UpdateUser(user.Username, func(stored *models.User) error {
+			if attempts.LockedUntil.After(stored.LockedUntil) {
+				stored.LockedUntil = attempts.LockedUntil
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("error al bloquear el usuario", "user", user.Username, "error", err)
+		}
+	}
+}
+
+func setLockedResponse(w http.ResponseWriter, r *http.Request, lockedUntil time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+	setError(w, r, http.StatusTooManyRequests, "too_many_attempts")
+}
+
+// requireAdmin writes the error response and returns false unless the token
+// belongs to a user with the admin role.
+func (h *Handlers) requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userName, err := extractUserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		setError(w, r, http.StatusUnauthorized, "token_invalid")
+		return "", false
+	}
+	storedUser, err := h.users.GetByUsername(userName)
+	if err != nil || !storedUser.HasRole("admin") {
+		setError(w, r, http.StatusForbidden, "admin_required")
+		return "", false
+	}
+	return userName, true
+}
+
+// isBootstrapAdmin reports whether username is listed in ADMIN_USERS, which
+// grants the admin role on registration.
+func isBootstrapAdmin(username string) bool {
+	for _, admin := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if strings.TrimSpace(admin) == username {
+			return true
+		}
+	}
+	return false
+}
+
+func issueToken(username string, teams []string) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": username,
+		"exp": time.Now().Add(24 * time.Hour).Unix(),
+	}
+	if len(teams) > 0 {
+		claims["teams"] = teams
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(middleware.JwtSecret)
 }
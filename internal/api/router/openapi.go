@@ -0,0 +1,201 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"faas-project/internal/api/apierror"
+)
+
+// OpenAPI describes the routes as an OpenAPI 3 document. Request and
+// response schemas are generated from the Body and Response values: struct
+// fields follow their json tags and those without omitempty are required.
+// Named structs are kept in components/schemas and referenced by name.
+func (rt *Router) OpenAPI(title, version, serverURL string) map[string]any {
+	schemas := map[string]any{}
+	gen := &schemaGenerator{schemas: schemas}
+	errorSchema := gen.schema(reflect.TypeOf(apierror.Error{}))
+
+	paths := map[string]map[string]any{}
+	for _, route := range rt.routes {
+		operation := map[string]any{
+			"operationId": operationID(route),
+			"summary":     route.Summary,
+			"responses":   gen.responses(route, errorSchema),
+		}
+		if route.Tag != "" {
+			operation["tags"] = []string{route.Tag}
+		}
+		if route.Auth {
+			operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+		}
+		var parameters []map[string]any
+		for _, name := range pathParams(route.Path) {
+			parameters = append(parameters, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, param := range route.Query {
+			paramType := param.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			parameters = append(parameters, map[string]any{
+				"name": param.Name, "in": "query", "description": param.Description,
+				"schema": map[string]any{"type": paramType},
+			})
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+		if route.Body != nil {
+			content := map[string]any{}
+			bodySchema := gen.schema(reflect.TypeOf(route.Body))
+			for _, contentType := range route.consumes() {
+				content[contentType] = map[string]any{"schema": bodySchema}
+			}
+			operation["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]any{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": version},
+		"servers": []map[string]any{{"url": serverURL}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func (gen *schemaGenerator) responses(route Route, errorSchema map[string]any) map[string]any {
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if route.Response != nil {
+		responseSchema := gen.schema(reflect.TypeOf(route.Response))
+		content := map[string]any{}
+		for _, contentType := range route.produces() {
+			content[contentType] = map[string]any{"schema": responseSchema}
+		}
+		success["content"] = content
+	}
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     map[string]any{jsonType: map[string]any{"schema": errorSchema}},
+	}
+	return map[string]any{
+		strconv.Itoa(status): success,
+		"default":            errorResponse,
+	}
+}
+
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(strings.Trim(route.Path, "/"), "/") {
+		part = strings.Trim(part, "{}")
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '.' || r == '_' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			names = append(names, part[1:len(part)-1])
+		}
+	}
+	return names
+}
+
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (gen *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return gen.schema(t.Elem())
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": gen.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": gen.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return gen.object(t)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := gen.schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			gen.schemas[name] = map[string]any{}
+			gen.schemas[name] = gen.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (gen *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = gen.schema(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	object := map[string]any{"type": "object", "properties": properties}
+	if required != nil {
+		sort.Strings(required)
+		object["required"] = required
+	}
+	return object
+}
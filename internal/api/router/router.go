@@ -0,0 +1,179 @@
+// Package router dispatches API requests by method and path and describes
+// the routes it serves as an OpenAPI document.
+package router
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/i18n"
+)
+
+// Route is one endpoint. Path segments written as {name} match any single
+// segment, available to the handler through Param. The remaining fields
+// document the route in the OpenAPI document.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+
+	Summary string
+	Tag     string
+	// Auth marks routes that need a bearer token.
+	Auth  bool
+	Query []QueryParam
+	// Body and Response are values of the request and response types, used
+	// to generate their schemas. Status is the success status (200 if 0).
+	Body     any
+	Response any
+	Status   int
+	// Consumes and Produces default to application/json.
+	Consumes []string
+	Produces []string
+}
+
+// QueryParam is a documented query parameter.
+type QueryParam struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+const jsonType = "application/json"
+
+func (route Route) consumes() []string {
+	if len(route.Consumes) == 0 {
+		return []string{jsonType}
+	}
+	return route.Consumes
+}
+
+func (route Route) produces() []string {
+	if len(route.Produces) == 0 {
+		return []string{jsonType}
+	}
+	return route.Produces
+}
+
+type Router struct {
+	routes []Route
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+func (rt *Router) Handle(route Route) {
+	rt.routes = append(rt.routes, route)
+}
+
+func (rt *Router) Routes() []Route {
+	return rt.routes
+}
+
+type paramsKey struct{}
+
+// Param returns the value of the {name} segment of the matched route.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// Handler serves the routes. Paths that exist with another method get a
+// 405 with the Allow header, and a 406 is returned when the Accept header
+// rules out every type the route produces. With strict set, request bodies
+// with a Content-Type the route does not consume are refused with a 415;
+// the unversioned aliases are lenient because older clients do not always
+// send one.
+func (rt *Router) Handler(strict bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, route := range rt.routes {
+			params, ok := match(route.Path, r.URL.Path)
+			if !ok {
+				continue
+			}
+			if route.Method != r.Method {
+				allowed = append(allowed, route.Method)
+				continue
+			}
+			if !acceptable(r.Header.Get("Accept"), route.produces()) {
+				apierror.Write(w, http.StatusNotAcceptable, apierror.NotAcceptable,
+					i18n.Message(i18n.FromRequest(r), apierror.NotAcceptable, strings.Join(route.produces(), ", ")))
+				return
+			}
+			if strict && r.ContentLength != 0 && !consumable(r.Header.Get("Content-Type"), route.consumes()) {
+				apierror.Write(w, http.StatusUnsupportedMediaType, apierror.UnsupportedMediaType,
+					i18n.Message(i18n.FromRequest(r), apierror.UnsupportedMediaType, strings.Join(route.consumes(), ", ")))
+				return
+			}
+			route.Handler(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+			return
+		}
+		if len(allowed) > 0 {
+			sort.Strings(allowed)
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, i18n.Message(i18n.FromRequest(r), apierror.MethodNotAllowed))
+			return
+		}
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, i18n.Message(i18n.FromRequest(r), apierror.NotFound))
+	})
+}
+
+func match(pattern, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[part[1:len(part)-1]] = pathParts[i]
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// acceptable reports whether the Accept header allows one of the types. A
+// missing header accepts anything.
+func acceptable(accept string, types []string) bool {
+	if accept == "" {
+		return true
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		for _, t := range types {
+			if mediaRange == "*/*" || mediaRange == t ||
+				(strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(t, strings.TrimSuffix(mediaRange, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func consumable(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
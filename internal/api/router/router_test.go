@@ -0,0 +1,131 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		params  map[string]string
+		ok      bool
+	}{
+		{"/functions", "/functions", map[string]string{}, true},
+		{"/functions", "/functions/", map[string]string{}, true},
+		{"/functions", "/function", nil, false},
+		{"/function/{name}", "/function/hello", map[string]string{"name": "hello"}, true},
+		{"/function/{name}", "/function/", nil, false},
+		{"/function/{name}", "/function", nil, false},
+		{"/function/{name}", "/function/hello/logs", nil, false},
+		{"/function/{name}/logs", "/function/hello/logs", map[string]string{"name": "hello"}, true},
+		{"/function/{name}/logs", "/function/hello/stats", nil, false},
+		{"/v1/{a}/{b}", "/v1/x/y", map[string]string{"a": "x", "b": "y"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			params, ok := match(test.pattern, test.path)
+			if ok != test.ok || (ok && !reflect.DeepEqual(params, test.params)) {
+				t.Errorf("match(%q, %q) = %v, %v, want %v, %v", test.pattern, test.path, params, ok, test.params, test.ok)
+			}
+		})
+	}
+}
+
+func TestAcceptable(t *testing.T) {
+	tests := []struct {
+		accept string
+		types  []string
+		want   bool
+	}{
+		{"", []string{"application/json"}, true},
+		{"application/json", []string{"application/json"}, true},
+		{"*/*", []string{"application/json"}, true},
+		{"application/*", []string{"application/json"}, true},
+		{"text/*", []string{"application/json"}, false},
+		{"text/html", []string{"application/json"}, false},
+		{"text/html, application/json;q=0.9", []string{"application/json"}, true},
+		{"application/json;q=0", []string{"application/json"}, false},
+		{"application/yaml", []string{"application/json", "application/yaml"}, true},
+		{"not a type", []string{"application/json"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			if got := acceptable(test.accept, test.types); got != test.want {
+				t.Errorf("acceptable(%q, %v) = %v, want %v", test.accept, test.types, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rt := New()
+	rt.Handle(Route{Method: http.MethodGet, Path: "/function/{name}", Handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Param(r, "name")))
+	}})
+	rt.Handle(Route{Method: http.MethodDelete, Path: "/function/{name}", Handler: func(w http.ResponseWriter, r *http.Request) {}})
+	rt.Handle(Route{Method: http.MethodPost, Path: "/function", Handler: func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}})
+
+	tests := []struct {
+		name        string
+		strict      bool
+		method      string
+		path        string
+		accept      string
+		contentType string
+		body        string
+		status      int
+		code        string
+		allow       string
+		response    string
+	}{
+		{name: "param", method: http.MethodGet, path: "/function/hello", status: http.StatusOK, response: "hello"},
+		{name: "not found", method: http.MethodGet, path: "/nothing", status: http.StatusNotFound, code: "not_found"},
+		{name: "method not allowed", method: http.MethodPut, path: "/function/hello", status: http.StatusMethodNotAllowed, code: "method_not_allowed", allow: "DELETE, GET"},
+		{name: "not acceptable", method: http.MethodGet, path: "/function/hello", accept: "text/html", status: http.StatusNotAcceptable, code: "not_acceptable"},
+		{name: "acceptable", method: http.MethodGet, path: "/function/hello", accept: "text/html, */*;q=0.1", status: http.StatusOK, response: "hello"},
+		{name: "strict json", strict: true, method: http.MethodPost, path: "/function", contentType: "application/json; charset=utf-8", body: "{}", status: http.StatusCreated},
+		{name: "strict wrong type", strict: true, method: http.MethodPost, path: "/function", contentType: "text/plain", body: "{}", status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "strict no type", strict: true, method: http.MethodPost, path: "/function", body: "{}", status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "strict no body", strict: true, method: http.MethodPost, path: "/function", status: http.StatusCreated},
+		{name: "lenient wrong type", method: http.MethodPost, path: "/function", contentType: "text/plain", body: "{}", status: http.StatusCreated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+			rt.Handler(test.strict).ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, test.status, w.Body)
+			}
+			if allow := w.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("Allow = %q, want %q", allow, test.allow)
+			}
+			if test.code != "" {
+				var body struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != test.code {
+					t.Errorf("body = %s, want code %q", w.Body, test.code)
+				}
+			}
+			if test.response != "" && w.Body.String() != test.response {
+				t.Errorf("body = %q, want %q", w.Body, test.response)
+			}
+		})
+	}
+}
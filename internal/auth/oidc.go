@@ -0,0 +1,410 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCConfig holds the settings of the external identity provider, read from
+// the environment by LoadOIDCConfig.
+type OIDCConfig struct {
+	Issuer            string
+	ClientID          string
+	ClientSecret      string
+	RedirectURL       string
+	Audience          string
+	Scopes            []string
+	UsernameClaim     string
+	GroupsClaim       string
+	DisableLocalLogin bool
+	// AdminTeams are the teams whose members get the admin role
+	AdminTeams []string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Identity is the local view of an authenticated IdP subject. Issuer and
+// Subject identify it at the IdP; Username is the local account it maps to.
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Teams    []string
+}
+
+// OIDCUserPrefix starts the names of the local accounts of IdP users.
+// Local registration cannot use it, so an IdP user never maps onto an
+// account created with a password.
+const OIDCUserPrefix = "oidc-"
+
+// OIDCUsername returns the local account of the IdP subject sub of
+// issuer: "oidc-<name>-<hash>", where name is the readable claim reduced to
+// the characters valid in KV keys and hash comes from issuer and sub, so
+// two subjects never share an account even if their names match.
+func OIDCUsername(issuer, sub, name string) string {
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(clean) > 32 {
+		clean = clean[:32]
+	}
+	if clean == "" {
+		clean = "user"
+	}
+	sum := sha256.Sum256([]byte(issuer + "\x00" + sub))
+	return OIDCUserPrefix + clean + "-" + hex.EncodeToString(sum[:6])
+}
+
+type OIDCProvider struct {
+	config    OIDCConfig
+	discovery discoveryDocument
+	client    *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+var provider *OIDCProvider
+
+func LoadOIDCConfig() OIDCConfig {
+	config := OIDCConfig{
+		Issuer:            strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:          os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:      os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:       os.Getenv("OIDC_REDIRECT_URL"),
+		Audience:          os.Getenv("OIDC_AUDIENCE"),
+		UsernameClaim:     os.Getenv("OIDC_USERNAME_CLAIM"),
+		GroupsClaim:       os.Getenv("OIDC_GROUPS_CLAIM"),
+		DisableLocalLogin: os.Getenv("OIDC_DISABLE_LOCAL_LOGIN") == "true",
+		Scopes:            []string{"openid", "profile", "email"},
+	}
+	for _, team := range strings.Split(os.Getenv("OIDC_ADMIN_TEAMS"), ",") {
+		if team = strings.TrimSpace(team); team != "" {
+			config.AdminTeams = append(config.AdminTeams, team)
+		}
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	if config.Audience == "" {
+		config.Audience = config.ClientID
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	return config
+}
+
+// InitOIDC fetches the issuer's discovery document and JWKS. It is a no-op
+// when no issuer is configured.
+func InitOIDC(config OIDCConfig) error {
+	if config.Issuer == "" {
+		return nil
+	}
+	p := &OIDCProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   map[string]interface{}{},
+	}
+	if err := p.getJSON(config.Issuer+"/.well-known/openid-configuration", &p.discovery); err != nil {
+		return fmt.Errorf("discovery OIDC: %w", err)
+	}
+	if strings.TrimSuffix(p.discovery.Issuer, "/") != config.Issuer {
+		return fmt.Errorf("discovery OIDC: issuer %q no coincide con %q", p.discovery.Issuer, config.Issuer)
+	}
+	if err := p.refreshKeys(); err != nil {
+		return err
+	}
+	provider = p
+	return nil
+}
+
+func GetOIDCProvider() *OIDCProvider {
+	return provider
+}
+
+func OIDCEnabled() bool {
+	return provider != nil
+}
+
+func LocalLoginDisabled() bool {
+	return provider != nil && provider.config.DisableLocalLogin
+}
+
+// AdminRole reports whether the teams of identity grant the admin role, and
+// whether OIDC_ADMIN_TEAMS is set at all, in which case the role of the
+// account follows the teams.
+func (p *OIDCProvider) AdminRole(identity Identity) (admin bool, managed bool) {
+	if len(p.config.AdminTeams) == 0 {
+		return false, false
+	}
+	for _, team := range identity.Teams {
+		if slices.Contains(p.config.AdminTeams, team) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func (p *OIDCProvider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *OIDCProvider) refreshKeys() error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return fmt.Errorf("JWKS: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *OIDCProvider) key(kid string) (interface{}, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	fetched := p.keysFetched
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	// Unknown kid: the IdP may have rotated its keys. Refetch at most once a minute.
+	if time.Since(fetched) > time.Minute {
+		if err := p.refreshKeys(); err != nil {
+			return nil, err
+		}
+		p.mu.RLock()
+		key, ok = p.keys[kid]
+		p.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("clave %q no encontrada en el JWKS", kid)
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curva no soportada: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("tipo de clave no soportado: %s", k.Kty)
+}
+
+// Verify validates a token issued by the IdP (signature against the JWKS,
+// issuer, audience and expiry) and maps its claims to a local identity.
+func (p *OIDCProvider) Verify(tokenString string) (Identity, error) {
+	claims, err := p.parse(tokenString, p.config.Audience)
+	if err != nil {
+		return Identity{}, err
+	}
+	return p.identity(claims)
+}
+
+func (p *OIDCProvider) parse(tokenString, audience string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("token inválido")
+	}
+	if !claims.VerifyIssuer(p.config.Issuer, true) && !claims.VerifyIssuer(p.config.Issuer+"/", true) {
+		return nil, fmt.Errorf("issuer inválido")
+	}
+	if !claims.VerifyAudience(audience, true) {
+		return nil, fmt.Errorf("audience inválida")
+	}
+	return claims, nil
+}
+
+func (p *OIDCProvider) identity(claims jwt.MapClaims) (Identity, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("el token no contiene el claim \"sub\"")
+	}
+	name, _ := claims[p.config.UsernameClaim].(string)
+	if name == "" {
+		name = sub
+	}
+	identity := Identity{
+		Issuer:   p.config.Issuer,
+		Subject:  sub,
+		Username: OIDCUsername(p.config.Issuer, sub, name),
+	}
+	switch groups := claims[p.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Teams = append(identity.Teams, s)
+			}
+		}
+	case string:
+		identity.Teams = strings.Fields(groups)
+	}
+	return identity, nil
+}
+
+// AuthorizationURL builds the redirect to the IdP for the authorization code
+// flow with PKCE (S256).
+func (p *OIDCProvider) AuthorizationURL(state, codeVerifier string) string {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	values := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// Exchange trades an authorization code for tokens and returns the verified
+// identity from the ID token.
+func (p *OIDCProvider) Exchange(code, codeVerifier string) (Identity, error) {
+	values := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret != "" {
+		values.Set("client_secret", p.config.ClientSecret)
+	}
+	resp, err := p.client.PostForm(p.discovery.TokenEndpoint, values)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return Identity{}, err
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, fmt.Errorf("respuesta sin id_token")
+	}
+
+	// The ID token audience is always the client ID, even if access tokens
+	// are validated against a different audience.
+	claims, err := p.parse(tokens.IDToken, p.config.ClientID)
+	if err != nil {
+		return Identity{}, err
+	}
+	return p.identity(claims)
+}
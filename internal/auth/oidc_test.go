@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadOIDCAdminTeams(t *testing.T) {
+	t.Setenv("OIDC_ADMIN_TEAMS", " platform, ,sre ")
+	if got := LoadOIDCConfig().AdminTeams; !reflect.DeepEqual(got, []string{"platform", "sre"}) {
+		t.Errorf("AdminTeams = %q, want [platform sre]", got)
+	}
+}
+
+func TestAdminRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminTeams  []string
+		teams       []string
+		wantAdmin   bool
+		wantManaged bool
+	}{
+		{name: "not configured", teams: []string{"platform"}},
+		{name: "member", adminTeams: []string{"platform", "sre"}, teams: []string{"web", "sre"}, wantAdmin: true, wantManaged: true},
+		{name: "not a member", adminTeams: []string{"platform"}, teams: []string{"web"}, wantManaged: true},
+		{name: "no teams", adminTeams: []string{"platform"}, wantManaged: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &OIDCProvider{config: OIDCConfig{AdminTeams: test.adminTeams}}
+			admin, managed := p.AdminRole(Identity{Teams: test.teams})
+			if admin != test.wantAdmin || managed != test.wantManaged {
+				t.Errorf("AdminRole = %v, %v, want %v, %v", admin, managed, test.wantAdmin, test.wantManaged)
+			}
+		})
+	}
+}
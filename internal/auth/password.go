@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"faas-project/internal/i18n"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy describes the requirements a new password must meet.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// LockoutPolicy controls the exponential lockout after repeated failed logins:
+// once MaxAttempts is reached every further failure doubles the lock, starting
+// at BaseLockout and never exceeding MaxLockout.
+type LockoutPolicy struct {
+	MaxAttempts int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+func LoadPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     envInt("PASSWORD_MIN_LENGTH", 8),
+		RequireUpper:  os.Getenv("PASSWORD_REQUIRE_UPPER") == "true",
+		RequireLower:  os.Getenv("PASSWORD_REQUIRE_LOWER") == "true",
+		RequireDigit:  os.Getenv("PASSWORD_REQUIRE_DIGIT") == "true",
+		RequireSymbol: os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true",
+	}
+}
+
+func LoadLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts: envInt("LOGIN_MAX_ATTEMPTS", 5),
+		BaseLockout: time.Duration(envInt("LOGIN_LOCKOUT_SECONDS", 30)) * time.Second,
+		MaxLockout:  time.Duration(envInt("LOGIN_LOCKOUT_MAX_SECONDS", 3600)) * time.Second,
+	}
+}
+
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return i18n.New("password_too_short", p.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		return i18n.New("password_needs_upper")
+	}
+	if p.RequireLower && !lower {
+		return i18n.New("password_needs_lower")
+	}
+	if p.RequireDigit && !digit {
+		return i18n.New("password_needs_digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return i18n.New("password_needs_symbol")
+	}
+	return nil
+}
+
+// LockoutFor returns how long to lock after the given number of consecutive
+// failures, or zero if the threshold has not been reached.
+func (p LockoutPolicy) LockoutFor(failures int) time.Duration {
+	if p.MaxAttempts <= 0 || failures < p.MaxAttempts {
+		return 0
+	}
+	lockout := float64(p.BaseLockout) * math.Pow(2, float64(failures-p.MaxAttempts))
+	if lockout > float64(p.MaxLockout) {
+		return p.MaxLockout
+	}
+	return time.Duration(lockout)
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"faas-project/internal/i18n"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		code     string
+	}{
+		{"long enough", PasswordPolicy{MinLength: 8}, "abcdefgh", ""},
+		{"too short", PasswordPolicy{MinLength: 8}, "abcdefg", "password_too_short"},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "ñññ", "password_too_short"},
+		{"all classes", strict, "Abcdef1!", ""},
+		{"no upper", strict, "abcdef1!", "password_needs_upper"},
+		{"no lower", strict, "ABCDEF1!", "password_needs_lower"},
+		{"no digit", strict, "Abcdefg!", "password_needs_digit"},
+		{"no symbol", strict, "Abcdefg1", "password_needs_symbol"},
+		{"unicode classes", strict, "Ñandú1€x", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate(test.password)
+			code, _ := i18n.Code(err)
+			if code != test.code {
+				t.Errorf("Validate(%q) = %v, want code %q", test.password, err, test.code)
+			}
+		})
+	}
+}
+
+func TestLockoutFor(t *testing.T) {
+	policy := LockoutPolicy{MaxAttempts: 3, BaseLockout: 30 * time.Second, MaxLockout: 5 * time.Minute}
+	tests := []struct {
+		name     string
+		policy   LockoutPolicy
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", policy, 0, 0},
+		{"below threshold", policy, 2, 0},
+		{"at threshold", policy, 3, 30 * time.Second},
+		{"doubles", policy, 4, time.Minute},
+		{"doubles again", policy, 5, 2 * time.Minute},
+		{"capped", policy, 7, 5 * time.Minute},
+		{"capped far beyond", policy, 1000, 5 * time.Minute},
+		{"disabled", LockoutPolicy{BaseLockout: time.Second, MaxLockout: time.Minute}, 10, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.LockoutFor(test.failures); got != test.want {
+				t.Errorf("LockoutFor(%d) = %v, want %v", test.failures, got, test.want)
+			}
+		})
+	}
+}
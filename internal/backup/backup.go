@@ -0,0 +1,353 @@
+// Package backup writes the platform state kept in JetStream to a portable
+// archive and restores it into another NATS server.
+//
+// The archive is a gzipped tar with a manifest.json, one kv/<bucket>.jsonl
+// file per KV bucket and one streams/<stream>.jsonl file per stream, each
+// line holding one entry or message.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"faas-project/internal/metering"
+	"faas-project/internal/repository"
+
+	"github.com/nats-io/nats.go"
+)
+
+// FormatVersion is the version of the archive layout.
+const FormatVersion = 1
+
+const formatName = "faas-backup"
+
+// Buckets are the KV buckets with persistent state. Sessions, login
+// attempts, rate limit counters, aggregated executions, locks and worker
+// heartbeats expire on their own and are not backed up. user_functions only
+// exists on data not migrated yet.
+var Buckets = []string{"users", "functions", "user_functions", "platform_config", "platform_meta", "registry_credentials", "usage"}
+
+// DefaultStreams are the streams backed up unless told otherwise. LOGS is
+// left out because it is usually large and of little use elsewhere.
+var DefaultStreams = []string{"AUDIT", "EXECUTIONS", "USAGE"}
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format        string         `json:"format"`
+	Version       int            `json:"version"`
+	SchemaVersion int            `json:"schemaVersion"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	Buckets       map[string]int `json:"buckets"`
+	Streams       map[string]int `json:"streams"`
+}
+
+type kvRecord struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type streamRecord struct {
+	Subject string      `json:"subject"`
+	Header  nats.Header `json:"header,omitempty"`
+	Data    []byte      `json:"data"`
+	Time    time.Time   `json:"time"`
+}
+
+// Write snapshots the buckets and streams into w and returns the manifest
+// written. Buckets and streams that do not exist are skipped.
+func Write(w io.Writer, js nats.JetStreamContext, streams []string) (Manifest, error) {
+	manifest := Manifest{
+		Format:    formatName,
+		Version:   FormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Buckets:   map[string]int{},
+		Streams:   map[string]int{},
+	}
+	// The version of the data, which may not be migrated yet
+	schemaVersion, err := repository.ReadSchemaVersion(js)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.SchemaVersion = schemaVersion
+	files := map[string][]byte{}
+
+	for _, bucket := range Buckets {
+		kv, err := js.KeyValue(bucket)
+		if err == nats.ErrBucketNotFound {
+			continue
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		data, count, err := dumpBucket(kv)
+		if err != nil {
+			return manifest, fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		files["kv/"+bucket+".jsonl"] = data
+		manifest.Buckets[bucket] = count
+	}
+	for _, stream := range streams {
+		data, count, err := dumpStream(js, stream)
+		if err == nats.ErrStreamNotFound {
+			continue
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("stream %s: %w", stream, err)
+		}
+		files["streams/"+stream+".jsonl"] = data
+		manifest.Streams[stream] = count
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeFile(archive, "manifest.json", manifestData, manifest.CreatedAt); err != nil {
+		return manifest, err
+	}
+	for name, data := range files {
+		if err := writeFile(archive, name, data, manifest.CreatedAt); err != nil {
+			return manifest, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+func writeFile(archive *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := archive.Write(data)
+	return err
+}
+
+func dumpBucket(kv nats.KeyValue) ([]byte, int, error) {
+	watcher, err := kv.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer watcher.Stop()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	count := 0
+	// The watcher sends the current values and then a nil entry
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		if err := encoder.Encode(kvRecord{Key: entry.Key(), Value: entry.Value()}); err != nil {
+			return nil, 0, err
+		}
+		count++
+	}
+	return buf.Bytes(), count, nil
+}
+
+func dumpStream(js nats.JetStreamContext, stream string) ([]byte, int, error) {
+	info, err := js.StreamInfo(stream)
+	if err != nil {
+		return nil, 0, err
+	}
+	var buf bytes.Buffer
+	if info.State.Msgs == 0 {
+		return buf.Bytes(), 0, nil
+	}
+	sub, err := js.SubscribeSync("", nats.BindStream(stream), nats.OrderedConsumer(), nats.DeliverAll())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer sub.Unsubscribe()
+
+	encoder := json.NewEncoder(&buf)
+	count := 0
+	for {
+		msg, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			return nil, 0, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, 0, err
+		}
+		record := streamRecord{Subject: msg.Subject, Header: msg.Header, Data: msg.Data, Time: meta.Timestamp}
+		if err := encoder.Encode(record); err != nil {
+			return nil, 0, err
+		}
+		count++
+		if meta.NumPending == 0 {
+			return buf.Bytes(), count, nil
+		}
+	}
+}
+
+// RestoreOptions tune Restore.
+type RestoreOptions struct {
+	// Force writes into buckets and streams that already hold data.
+	Force bool
+}
+
+// Read loads an archive and checks that this release can restore it.
+func Read(r io.Reader) (Manifest, map[string][]byte, error) {
+	var manifest Manifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("archivo de backup inválido: %w", err)
+	}
+	archive := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("archivo de backup inválido: %w", err)
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return manifest, nil, err
+		}
+		files[path.Clean(header.Name)] = data
+	}
+
+	data, ok := files["manifest.json"]
+	if !ok {
+		return manifest, nil, errors.New("el backup no tiene manifest.json")
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, nil, fmt.Errorf("manifest.json inválido: %w", err)
+	}
+	if manifest.Format != formatName {
+		return manifest, nil, fmt.Errorf("formato de backup desconocido: %q", manifest.Format)
+	}
+	if manifest.Version > FormatVersion {
+		return manifest, nil, fmt.Errorf("versión de backup %d no soportada (máximo %d)", manifest.Version, FormatVersion)
+	}
+	// Older data is upgraded by the migrations when the API starts; data
+	// written by a newer release could be misread by this one
+	if manifest.SchemaVersion > repository.SchemaVersion {
+		return manifest, nil, fmt.Errorf("el backup tiene el esquema %d, más nuevo que el de esta versión (%d)", manifest.SchemaVersion, repository.SchemaVersion)
+	}
+	return manifest, files, nil
+}
+
+// Restore writes the contents of an archive read with Read. The streams
+// must exist (message.InitNats creates them) and, unless opts.Force is set,
+// the buckets and streams must be empty; this is checked for all of them
+// before anything is written. platform_meta is not checked: it only holds
+// the schema version, which Restore sets anyway.
+//
+// Stream messages are published again, so they are stored with the time of
+// the restore; the ones older than the stream's MaxAge are dropped, as they
+// would have expired. If both the USAGE stream and the usage bucket are
+// restored, the usage aggregator is moved past the restored messages so
+// they are not counted twice.
+func Restore(js nats.JetStreamContext, manifest Manifest, files map[string][]byte, opts RestoreOptions) error {
+	if !opts.Force {
+		for bucket := range manifest.Buckets {
+			if bucket == "platform_meta" {
+				continue
+			}
+			kv, err := js.KeyValue(bucket)
+			if err == nats.ErrBucketNotFound {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("bucket %s: %w", bucket, err)
+			}
+			status, err := kv.Status()
+			if err != nil {
+				return fmt.Errorf("bucket %s: %w", bucket, err)
+			}
+			if status.Values() > 0 {
+				return fmt.Errorf("el bucket %s no está vacío", bucket)
+			}
+		}
+		for stream := range manifest.Streams {
+			info, err := js.StreamInfo(stream)
+			if err != nil {
+				return fmt.Errorf("stream %s: %w", stream, err)
+			}
+			if info.State.Msgs > 0 {
+				return fmt.Errorf("el stream %s no está vacío", stream)
+			}
+		}
+	}
+
+	for bucket := range manifest.Buckets {
+		kv, err := js.KeyValue(bucket)
+		if err == nats.ErrBucketNotFound {
+			kv, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket})
+		}
+		if err != nil {
+			return fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		err = eachLine(files["kv/"+bucket+".jsonl"], func(line []byte) error {
+			var record kvRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return err
+			}
+			_, err := kv.Put(record.Key, record.Value)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+	}
+	for stream := range manifest.Streams {
+		info, err := js.StreamInfo(stream)
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", stream, err)
+		}
+		err = eachLine(files["streams/"+stream+".jsonl"], func(line []byte) error {
+			var record streamRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return err
+			}
+			if info.Config.MaxAge > 0 && !record.Time.IsZero() && time.Since(record.Time) > info.Config.MaxAge {
+				return nil
+			}
+			_, err := js.PublishMsg(&nats.Msg{Subject: record.Subject, Header: record.Header, Data: record.Data})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", stream, err)
+		}
+	}
+	if _, ok := manifest.Streams["USAGE"]; ok {
+		if _, ok := manifest.Buckets["usage"]; ok {
+			if err := metering.SkipAggregated(js); err != nil {
+				return fmt.Errorf("stream USAGE: %w", err)
+			}
+		}
+	}
+	// The API migrates the restored data on its next start
+	return repository.SetSchemaVersion(js, manifest.SchemaVersion)
+}
+
+func eachLine(data []byte, fn func(line []byte) error) error {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
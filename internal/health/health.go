@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/nats-io/nats.go"
+)
+
+// checkTimeout bounds every check so a hung dependency fails the probe
+// instead of blocking it.
+const checkTimeout = 2 * time.Second
+
+// Check is one dependency probed by a health endpoint.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Handler runs the checks and answers 200 if all pass or 503 otherwise,
+// with the result of each one.
+func Handler(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		status := "ok"
+		results := map[string]string{}
+		for _, check := range checks {
+			if err := check.Run(ctx); err != nil {
+				status = "error"
+				results[check.Name] = err.Error()
+			} else {
+				results[check.Name] = "ok"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if status == "ok" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": status,
+			"checks": results,
+		})
+	}
+}
+
+// NATS passes while the connection to the server is up.
+func NATS(nc *nats.Conn) Check {
+	return Check{Name: "nats", Run: func(ctx context.Context) error {
+		if status := nc.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("conexión con NATS en estado %s", status)
+		}
+		return nil
+	}}
+}
+
+// KeyValue passes if the buckets can be read through JetStream.
+func KeyValue(js nats.JetStreamContext, buckets ...string) Check {
+	return Check{Name: "jetstream", Run: func(ctx context.Context) error {
+		for _, bucket := range buckets {
+			kv, err := js.KeyValue(bucket)
+			if err != nil {
+				return fmt.Errorf("bucket %s: %w", bucket, err)
+			}
+			if _, err := kv.Status(); err != nil {
+				return fmt.Errorf("bucket %s: %w", bucket, err)
+			}
+		}
+		return nil
+	}}
+}
+
+// Docker passes if the daemon answers a ping.
+func Docker(dockerClient *client.Client) Check {
+	return Check{Name: "docker", Run: func(ctx context.Context) error {
+		_, err := dockerClient.Ping(ctx)
+		return err
+	}}
+}
+
+// Subscription passes while the subscription is still active.
+func Subscription(sub *nats.Subscription) Check {
+	return Check{Name: "subscription", Run: func(ctx context.Context) error {
+		if !sub.IsValid() {
+			return fmt.Errorf("suscripción %s cerrada", sub.Subject)
+		}
+		return nil
+	}}
+}
+
+// Draining fails once shutdown has started, so the instance is taken out
+// of rotation before it stops.
+func Draining(draining *atomic.Bool) Check {
+	return Check{Name: "shutdown", Run: func(ctx context.Context) error {
+		if draining.Load() {
+			return fmt.Errorf("deteniéndose")
+		}
+		return nil
+	}}
+}
+
+// ShutdownTimeout is how long a component waits for in-flight work when
+// stopping, from SHUTDOWN_TIMEOUT (a Go duration) or def.
+func ShutdownTimeout(def time.Duration) time.Duration {
+	if timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		return timeout
+	}
+	return def
+}
@@ -0,0 +1,137 @@
+package i18n
+
+var english = map[string]string{
+	// Generic codes of apierror
+	"bad_request":            "Bad request",
+	"unauthorized":           "Authentication required",
+	"forbidden":              "Access denied",
+	"not_found":              "Route not found",
+	"method_not_allowed":     "Method not allowed",
+	"not_acceptable":         "Available response types: %s",
+	"conflict":               "Conflict with the current state",
+	"payload_too_large":      "Request too large",
+	"unsupported_media_type": "Supported content types: %s",
+	"too_many_requests":      "Too many requests",
+	"internal_error":         "Internal error",
+	"unavailable":            "Service unavailable",
+	"timeout":                "Timed out waiting for the response",
+
+	// Requests and authentication
+	"invalid_body":       "Invalid request body: %v",
+	"token_missing":      "Authorization header missing",
+	"token_malformed":    "Invalid Authorization header format",
+	"token_invalid":      "Invalid token",
+	"rate_limited":       "Rate limit exceeded",
+	"admin_required":     "Administrator permissions required",
+	"concurrent_changes": "Too many concurrent changes, try again",
+
+	// Users and login
+	"invalid_credentials":    "Invalid credentials",
+	"login_attempts_failed":  "Error checking the login attempts",
+	"too_many_attempts":      "Too many failed attempts, try again later",
+	"token_issue_failed":     "Could not issue the token",
+	"logged_in":              "User logged in successfully",
+	"username_required":      "Username required",
+	"user_exists":            "The user already exists",
+	"registration_failed":    "Error processing the registration",
+	"user_create_failed":     "Error creating the user",
+	"user_registered":        "User registered successfully",
+	"user_not_found":         "User not found",
+	"user_update_failed":     "Error updating the user",
+	"password_changed":       "Password changed successfully",
+	"password_reset":         "Password reset successfully",
+	"password_update_failed": "Error updating the password",
+	"password_too_short":     "the password must be at least %d characters long",
+	"password_needs_upper":   "the password must contain an uppercase letter",
+	"password_needs_lower":   "the password must contain a lowercase letter",
+	"password_needs_digit":   "the password must contain a digit",
+	"password_needs_symbol":  "the password must contain a symbol",
+	"plan_unknown":           "Unknown plan: %s",
+	"plan_updated":           "Plan updated successfully",
+	"oidc_disabled":          "OIDC login is not configured",
+	"oidc_start_failed":      "Error starting the OIDC login",
+	"oidc_rejected":          "The identity provider rejected the login: %s",
+	"oidc_params_required":   "The code and state parameters are required",
+	"oidc_state_invalid":     "Invalid or expired state",
+	"oidc_account_conflict":  "The local account is not linked to this identity of the provider",
+	"username_reserved":      "Usernames starting with %q are reserved for the OIDC login",
+
+	// Functions
+	"function_fields_required":  "Name and image are required",
+	"function_env_invalid":      "Invalid environment variable: %q",
+	"function_label_invalid":    "Invalid label: %q",
+	"label_selector_invalid":    "Invalid label selector: %q",
+	"sort_invalid":              "Invalid sort: %q (name, createdAt or updatedAt, with - for descending)",
+	"function_name_required":    "Function name required",
+	"function_not_found":        "Function not found for this user",
+	"function_exists":           "A function with that name already exists",
+	"function_forbidden":        "You are not allowed to run this function",
+	"functions_forbidden":       "You are not allowed to access these functions",
+	"function_list_failed":      "Error getting the user's functions",
+	"function_register_failed":  "Error registering the function",
+	"function_registered":       "Function registered successfully",
+	"function_delete_failed":    "Error deleting the function",
+	"function_deleted":          "Function deleted successfully",
+	"function_quota_reached":    "Quota of %d functions reached",
+	"param_invalid":             "Error decoding the parameter",
+	"invocation_encode_failed":  "Error encoding the execution request: %v",
+	"invocation_publish_failed": "Subscription error: %v",
+
+	// Logs, executions and audit
+	"since_invalid":         "Invalid since date",
+	"until_invalid":         "Invalid until date",
+	"limit_invalid":         "Invalid limit",
+	"limit_out_of_range":    "Invalid limit (maximum %d)",
+	"cursor_invalid":        "Invalid cursor",
+	"window_invalid":        "Invalid window",
+	"interval_invalid":      "Invalid interval (at most %d intervals)",
+	"logs_query_failed":     "Error querying the logs",
+	"history_query_failed":  "Error querying the history",
+	"audit_query_failed":    "Error querying the audit log",
+	"streaming_unsupported": "Streaming not supported",
+
+	// Manifests
+	"manifest_generate_failed":     "Error generating the manifest",
+	"manifest_too_large":           "Manifest too large (maximum %d bytes)",
+	"manifest_invalid":             "Invalid manifest: %v",
+	"manifest_version_required":    "Manifest version required",
+	"manifest_version_unsupported": "Unsupported manifest version: %d (maximum %d)",
+	"manifest_duplicate_function":  "Duplicate function in the manifest: %s",
+	"manifest_function_invalid":    "%s: %v",
+	"faasfile_too_large":           "File too large (maximum %d bytes)",
+	"faasfile_invalid":             "Invalid faas.yaml: %v",
+	"faasfile_version_unsupported": "Unsupported apiVersion: %q (expected %q)",
+
+	// Image policy, registries and sandbox
+	"image_policy_read_failed":           "Error getting the image policy",
+	"image_policy_save_failed":           "Error saving the image policy",
+	"image_policy_updated":               "Image policy updated",
+	"denied_image_empty":                 "Denied images cannot be empty",
+	"image_rejected":                     "Image rejected: %v",
+	"image_reference_invalid":            "invalid image reference %q: %v",
+	"image_registry_not_allowed":         "the registry %s is not allowed",
+	"image_digest_required":              "the image must be pinned by digest (image@sha256:...)",
+	"image_denied":                       "the image %s is denied",
+	"image_too_large":                    "the image takes %d MB and the maximum is %d MB",
+	"registry_required":                  "Registry required",
+	"registry_credentials_required":      "Registry username and password required",
+	"registry_credentials_save_failed":   "Error saving the registry credentials",
+	"registry_credentials_saved":         "Registry credentials saved",
+	"registry_credentials_delete_failed": "Error deleting the registry credentials",
+	"registry_credentials_deleted":       "Registry credentials deleted",
+	"sandbox_writable_rootfs":            "the policy does not allow a writable root filesystem",
+	"sandbox_capability_denied":          "the policy does not allow the capability %s",
+	"sandbox_root_denied":                "the policy does not allow running as root",
+	"sandbox_seccomp_denied":             "seccomp profile not allowed: %s",
+	"sandbox_runtime_denied":             "the policy does not allow the runtime %s",
+	"sandbox_network_unknown":            "unknown network mode: %s",
+	"sandbox_network_denied":             "the policy does not allow the network mode %s",
+	"sandbox_memory_out_of_range":        "the memory limit must be between 6 and %d MB",
+	"sandbox_tmpfs_invalid":              "invalid /tmp size: %q (a number with an optional k, m or g suffix, at most %d MB)",
+
+	// Usage and quotas
+	"usage_read_failed":       "Error getting the usage",
+	"quota_check_failed":      "Error checking the quota",
+	"daily_quota_exhausted":   "Daily quota of %d invocations exhausted",
+	"monthly_quota_exhausted": "Monthly quota of %.0f GB-seconds exhausted",
+}
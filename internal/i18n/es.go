@@ -0,0 +1,137 @@
+package i18n
+
+var spanish = map[string]string{
+	// Generic codes of apierror
+	"bad_request":            "Petición inválida",
+	"unauthorized":           "Autenticación requerida",
+	"forbidden":              "Acceso denegado",
+	"not_found":              "Ruta no encontrada",
+	"method_not_allowed":     "Método no permitido",
+	"not_acceptable":         "Tipos de respuesta disponibles: %s",
+	"conflict":               "Conflicto con el estado actual",
+	"payload_too_large":      "Petición demasiado grande",
+	"unsupported_media_type": "Tipos de contenido admitidos: %s",
+	"too_many_requests":      "Demasiadas peticiones",
+	"internal_error":         "Error interno",
+	"unavailable":            "Servicio no disponible",
+	"timeout":                "Timeout esperando respuesta",
+
+	// Requests and authentication
+	"invalid_body":       "Cuerpo de la petición inválido: %v",
+	"token_missing":      "Falta la cabecera Authorization",
+	"token_malformed":    "Formato de la cabecera Authorization inválido",
+	"token_invalid":      "Token inválido",
+	"rate_limited":       "Límite de peticiones superado",
+	"admin_required":     "Se requieren permisos de administrador",
+	"concurrent_changes": "Demasiados cambios simultáneos, inténtalo de nuevo",
+
+	// Users and login
+	"invalid_credentials":    "Credenciales inválidas",
+	"login_attempts_failed":  "Error al comprobar los intentos de login",
+	"too_many_attempts":      "Demasiados intentos fallidos, inténtalo más tarde",
+	"token_issue_failed":     "No se pudo generar el token",
+	"logged_in":              "Usuario logueado correctamente",
+	"username_required":      "Nombre de usuario requerido",
+	"user_exists":            "El usuario ya existe",
+	"registration_failed":    "Error al procesar el registro",
+	"user_create_failed":     "Error al crear el usuario",
+	"user_registered":        "Usuario registrado correctamente",
+	"user_not_found":         "Usuario no encontrado",
+	"user_update_failed":     "Error al actualizar el usuario",
+	"password_changed":       "Contraseña actualizada correctamente",
+	"password_reset":         "Contraseña restablecida correctamente",
+	"password_update_failed": "Error al actualizar la contraseña",
+	"password_too_short":     "la contraseña debe tener al menos %d caracteres",
+	"password_needs_upper":   "la contraseña debe contener una letra mayúscula",
+	"password_needs_lower":   "la contraseña debe contener una letra minúscula",
+	"password_needs_digit":   "la contraseña debe contener un número",
+	"password_needs_symbol":  "la contraseña debe contener un símbolo",
+	"plan_unknown":           "Plan desconocido: %s",
+	"plan_updated":           "Plan actualizado correctamente",
+	"oidc_disabled":          "Login OIDC no configurado",
+	"oidc_start_failed":      "Error al iniciar el login OIDC",
+	"oidc_rejected":          "El proveedor de identidad rechazó el login: %s",
+	"oidc_params_required":   "Parámetros code y state requeridos",
+	"oidc_state_invalid":     "State inválido o expirado",
+	"oidc_account_conflict":  "La cuenta local no está vinculada a esta identidad del proveedor",
+	"username_reserved":      "Los nombres de usuario que empiezan por %q están reservados para el login OIDC",
+
+	// Functions
+	"function_fields_required":  "Nombre e imagen son requeridos",
+	"function_env_invalid":      "Variable de entorno inválida: %q",
+	"function_label_invalid":    "Etiqueta inválida: %q",
+	"label_selector_invalid":    "Selector de etiquetas inválido: %q",
+	"sort_invalid":              "Orden inválido: %q (name, createdAt o updatedAt, con - para descendente)",
+	"function_name_required":    "Nombre de función requerido",
+	"function_not_found":        "Función no encontrada para este usuario",
+	"function_exists":           "Ya existe una función con ese nombre",
+	"function_forbidden":        "No tienes permisos para ejecutar esta función",
+	"functions_forbidden":       "No tienes permisos para acceder a estas funciones",
+	"function_list_failed":      "Error al obtener funciones del usuario",
+	"function_register_failed":  "Error al registrar la función",
+	"function_registered":       "Función registrada exitosamente",
+	"function_delete_failed":    "Error al eliminar la función",
+	"function_deleted":          "Función eliminada exitosamente",
+	"function_quota_reached":    "Cuota de %d funciones alcanzada",
+	"param_invalid":             "Error al decodificar el parámetro",
+	"invocation_encode_failed":  "Error al serializar la solicitud de ejecución: %v",
+	"invocation_publish_failed": "Error en subscripción: %v",
+
+	// Logs, executions and audit
+	"since_invalid":         "Fecha since inválida",
+	"until_invalid":         "Fecha until inválida",
+	"limit_invalid":         "Límite inválido",
+	"limit_out_of_range":    "Límite inválido (máximo %d)",
+	"cursor_invalid":        "Cursor inválido",
+	"window_invalid":        "Ventana inválida",
+	"interval_invalid":      "Intervalo inválido (máximo %d intervalos)",
+	"logs_query_failed":     "Error al consultar los logs",
+	"history_query_failed":  "Error al consultar el historial",
+	"audit_query_failed":    "Error al consultar la auditoría",
+	"streaming_unsupported": "Streaming no soportado",
+
+	// Manifests
+	"manifest_generate_failed":     "Error al generar el manifiesto",
+	"manifest_too_large":           "Manifiesto demasiado grande (máximo %d bytes)",
+	"manifest_invalid":             "Manifiesto inválido: %v",
+	"manifest_version_required":    "Versión del manifiesto requerida",
+	"manifest_version_unsupported": "Versión del manifiesto no soportada: %d (máximo %d)",
+	"manifest_duplicate_function":  "Función duplicada en el manifiesto: %s",
+	"manifest_function_invalid":    "%s: %v",
+	"faasfile_too_large":           "Fichero demasiado grande (máximo %d bytes)",
+	"faasfile_invalid":             "faas.yaml inválido: %v",
+	"faasfile_version_unsupported": "apiVersion no soportada: %q (se esperaba %q)",
+
+	// Image policy, registries and sandbox
+	"image_policy_read_failed":           "Error al obtener la política de imágenes",
+	"image_policy_save_failed":           "Error al guardar la política de imágenes",
+	"image_policy_updated":               "Política de imágenes actualizada",
+	"denied_image_empty":                 "Las imágenes denegadas no pueden estar vacías",
+	"image_rejected":                     "Imagen rechazada: %v",
+	"image_reference_invalid":            "referencia de imagen inválida %q: %v",
+	"image_registry_not_allowed":         "el registro %s no está permitido",
+	"image_digest_required":              "la imagen debe fijarse por digest (imagen@sha256:...)",
+	"image_denied":                       "la imagen %s está denegada",
+	"image_too_large":                    "la imagen ocupa %d MB y el máximo es %d MB",
+	"registry_required":                  "Registro requerido",
+	"registry_credentials_required":      "Usuario y contraseña del registro requeridos",
+	"registry_credentials_save_failed":   "Error al guardar las credenciales del registro",
+	"registry_credentials_saved":         "Credenciales del registro guardadas",
+	"registry_credentials_delete_failed": "Error al eliminar las credenciales del registro",
+	"registry_credentials_deleted":       "Credenciales del registro eliminadas",
+	"sandbox_writable_rootfs":            "la política no permite un sistema de ficheros raíz con escritura",
+	"sandbox_capability_denied":          "la política no permite la capability %s",
+	"sandbox_root_denied":                "la política no permite ejecutar como root",
+	"sandbox_seccomp_denied":             "perfil seccomp no permitido: %s",
+	"sandbox_runtime_denied":             "la política no permite el runtime %s",
+	"sandbox_network_unknown":            "modo de red desconocido: %s",
+	"sandbox_network_denied":             "la política no permite el modo de red %s",
+	"sandbox_memory_out_of_range":        "el límite de memoria debe estar entre 6 y %d MB",
+	"sandbox_tmpfs_invalid":              "tamaño de /tmp inválido: %q (número con sufijo k, m o g opcional, máximo %d MB)",
+
+	// Usage and quotas
+	"usage_read_failed":       "Error al obtener el consumo",
+	"quota_check_failed":      "Error al comprobar la cuota",
+	"daily_quota_exhausted":   "Cuota diaria de %d invocaciones agotada",
+	"monthly_quota_exhausted": "Cuota mensual de %.0f GB-segundos agotada",
+}
@@ -0,0 +1,136 @@
+// Package i18n translates the messages of the API. Every message has a
+// code, the one returned in the "code" field of the responses, and a text
+// per language in the catalogues of this package. The language is taken
+// from the Accept-Language header of the request.
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported languages. Default is used when the client does not ask for
+// any of them and for the texts missing in a catalogue.
+const (
+	Spanish = "es"
+	English = "en"
+	Default = Spanish
+)
+
+var catalogs = map[string]map[string]string{
+	Spanish: spanish,
+	English: english,
+}
+
+// Language returns the supported language the Accept-Language header
+// prefers, honouring its q values.
+func Language(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if base == "*" {
+			base = Default
+		}
+		if _, ok := catalogs[base]; ok && q > 0 {
+			candidates = append(candidates, candidate{lang: base, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// FromRequest returns the language of the response to r.
+func FromRequest(r *http.Request) string {
+	return Language(r.Header.Get("Accept-Language"))
+}
+
+type languageKey struct{}
+
+// WithLanguage stores lang in ctx for code that writes responses without
+// the request at hand.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// FromContext returns the language stored with WithLanguage, or Default.
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok {
+		return lang
+	}
+	return Default
+}
+
+// Message returns the text of code in lang, formatted with args. Args that
+// are Errors are translated too. Unknown codes are returned as is.
+func Message(lang, code string, args ...any) string {
+	format, ok := catalogs[lang][code]
+	if !ok {
+		if format, ok = catalogs[Default][code]; !ok {
+			return code
+		}
+	}
+	translated := make([]any, len(args))
+	for i, arg := range args {
+		if err, ok := arg.(error); ok {
+			arg = Translate(lang, err)
+		}
+		translated[i] = arg
+	}
+	return fmt.Sprintf(format, translated...)
+}
+
+// Error is an error with a catalogue code, so that the messages of the
+// packages the handlers call (password or image policies, sandbox...) reach
+// the client in its language. Error() is the text in Default.
+type Error struct {
+	Code string
+	Args []any
+}
+
+func New(code string, args ...any) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+func (e *Error) Error() string {
+	return Message(Default, e.Code, e.Args...)
+}
+
+// Translate returns the message of err in lang, or err.Error() if it is
+// not an Error.
+func Translate(lang string, err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return Message(lang, coded.Code, coded.Args...)
+	}
+	return err.Error()
+}
+
+// Code returns the code of err if it is an Error.
+func Code(err error) (string, bool) {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code, true
+	}
+	return "", false
+}
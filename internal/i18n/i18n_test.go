@@ -0,0 +1,44 @@
+package i18n
+
+import "testing"
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", Default},
+		{"en", English},
+		{"es", Spanish},
+		{"en-US", English},
+		{"EN-gb", English},
+		{"fr", Default},
+		{"fr, en;q=0.5", English},
+		{"es;q=0.4, en;q=0.8", English},
+		{"en;q=0.8, es", Spanish},
+		{"en;q=0, es;q=0.1", Spanish},
+		{"en;q=0", Default},
+		{"en;q=abc, es;q=0.1", Spanish},
+		{"*", Default},
+		{"de, *;q=0.5, en;q=0.6", English},
+	}
+	for _, test := range tests {
+		t.Run(test.acceptLanguage, func(t *testing.T) {
+			if got := Language(test.acceptLanguage); got != test.want {
+				t.Errorf("Language(%q) = %q, want %q", test.acceptLanguage, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCataloguesHaveTheSameKeys(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for other, otherCatalog := range catalogs {
+			for code := range catalog {
+				if _, ok := otherCatalog[code]; !ok {
+					t.Errorf("%q is in the %s catalogue but not in %s", code, lang, other)
+				}
+			}
+		}
+	}
+}
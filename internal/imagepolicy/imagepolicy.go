@@ -0,0 +1,94 @@
+package imagepolicy
+
+import (
+	"path"
+	"strings"
+
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+
+	"github.com/docker/distribution/reference"
+)
+
+// Image is a parsed, normalized image reference.
+type Image struct {
+	Registry   string
+	Repository string
+	Digest     string
+	Normalized string
+}
+
+func Parse(image string) (Image, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return Image{}, i18n.New("image_reference_invalid", image, err)
+	}
+	parsed := Image{
+		Registry:   reference.Domain(named),
+		Repository: reference.Path(named),
+		Normalized: reference.TagNameOnly(named).String(),
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		parsed.Digest = digested.Digest().String()
+	}
+	return parsed, nil
+}
+
+// Check evaluates the parts of the policy that only need the reference.
+// The size limit is checked by the worker once the image has been pulled.
+func Check(policy models.ImagePolicy, image string) error {
+	parsed, err := Parse(image)
+	if err != nil {
+		return err
+	}
+	if len(policy.AllowedRegistries) > 0 && !matchesAny(policy.AllowedRegistries, parsed.Registry) {
+		return i18n.New("image_registry_not_allowed", parsed.Registry)
+	}
+	if policy.RequireDigest && parsed.Digest == "" {
+		return i18n.New("image_digest_required")
+	}
+	for _, denied := range policy.DeniedImages {
+		if deniedMatches(denied, parsed) {
+			return i18n.New("image_denied", image)
+		}
+	}
+	return nil
+}
+
+func CheckSize(policy models.ImagePolicy, sizeBytes int64) error {
+	if policy.MaxImageSizeMB > 0 && sizeBytes > policy.MaxImageSizeMB*1024*1024 {
+		return i18n.New("image_too_large", sizeBytes/(1024*1024), policy.MaxImageSizeMB)
+	}
+	return nil
+}
+
+// deniedMatches accepts entries with or without registry and tag, and shell
+// patterns such as "docker.io/library/*".
+func deniedMatches(denied string, image Image) bool {
+	candidates := []string{
+		image.Registry + "/" + image.Repository,
+		image.Repository,
+		image.Normalized,
+	}
+	if deniedImage, err := Parse(denied); err == nil && !strings.ContainsAny(denied, "*?[") {
+		if strings.ContainsAny(denied, ":@") {
+			return deniedImage.Normalized == image.Normalized || (image.Digest != "" && deniedImage.Digest == image.Digest)
+		}
+		return deniedImage.Registry == image.Registry && deniedImage.Repository == image.Repository
+	}
+	for _, candidate := range candidates {
+		if ok, _ := path.Match(denied, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package imagepolicy
+
+import (
+	"testing"
+
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+)
+
+const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy models.ImagePolicy
+		image  string
+		code   string
+	}{
+		{"empty policy", models.ImagePolicy{}, "alpine", ""},
+		{"invalid reference", models.ImagePolicy{}, "Alpine:latest", "image_reference_invalid"},
+		{"allowed registry", models.ImagePolicy{AllowedRegistries: []string{"ghcr.io"}}, "ghcr.io/acme/app:1", ""},
+		{"docker hub is docker.io", models.ImagePolicy{AllowedRegistries: []string{"docker.io"}}, "alpine", ""},
+		{"registry not allowed", models.ImagePolicy{AllowedRegistries: []string{"ghcr.io"}}, "alpine", "image_registry_not_allowed"},
+		{"registry pattern", models.ImagePolicy{AllowedRegistries: []string{"*.example.com"}}, "registry.example.com/app", ""},
+		{"registry pattern does not match parent", models.ImagePolicy{AllowedRegistries: []string{"*.example.com"}}, "example.com/app", "image_registry_not_allowed"},
+		{"digest required", models.ImagePolicy{RequireDigest: true}, "alpine:3", "image_digest_required"},
+		{"digest given", models.ImagePolicy{RequireDigest: true}, "alpine@" + digest, ""},
+		{"denied repository", models.ImagePolicy{DeniedImages: []string{"alpine"}}, "docker.io/library/alpine:3", "image_denied"},
+		{"denied repository other registry", models.ImagePolicy{DeniedImages: []string{"alpine"}}, "ghcr.io/library/alpine:3", ""},
+		{"denied tag", models.ImagePolicy{DeniedImages: []string{"alpine:3"}}, "alpine:3", "image_denied"},
+		{"denied tag other tag", models.ImagePolicy{DeniedImages: []string{"alpine:3"}}, "alpine:edge", ""},
+		{"untagged is latest", models.ImagePolicy{DeniedImages: []string{"alpine:latest"}}, "alpine", "image_denied"},
+		{"denied digest", models.ImagePolicy{DeniedImages: []string{"alpine@" + digest}}, "alpine@" + digest, "image_denied"},
+		{"denied pattern", models.ImagePolicy{DeniedImages: []string{"docker.io/library/*"}}, "busybox", "image_denied"},
+		{"denied pattern other namespace", models.ImagePolicy{DeniedImages: []string{"docker.io/library/*"}}, "acme/app", ""},
+		{"denied repository pattern", models.ImagePolicy{DeniedImages: []string{"acme/*"}}, "ghcr.io/acme/app", "image_denied"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check(test.policy, test.image)
+			code, _ := i18n.Code(err)
+			if code != test.code {
+				t.Errorf("Check(%q) = %v, want code %q", test.image, err, test.code)
+			}
+		})
+	}
+}
+
+func TestCheckSize(t *testing.T) {
+	policy := models.ImagePolicy{MaxImageSizeMB: 10}
+	if err := CheckSize(policy, 10*1024*1024); err != nil {
+		t.Errorf("CheckSize at the limit = %v, want nil", err)
+	}
+	if err := CheckSize(policy, 10*1024*1024+1); err == nil {
+		t.Error("CheckSize over the limit = nil, want an error")
+	}
+	if err := CheckSize(models.ImagePolicy{}, 1<<40); err != nil {
+		t.Errorf("CheckSize without limit = %v, want nil", err)
+	}
+}
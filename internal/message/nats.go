@@ -1,6 +1,10 @@
 package message
 
 import (
+	"os"
+	"strconv"
+	"time"
+
 	"github.com/nats-io/nats.go"
 )
 
@@ -28,16 +32,193 @@ func InitNats(nc *nats.Conn) error {
 		}
 	}
 
-	_, err = js.KeyValue("user_functions")
+	_, err = js.KeyValue("functions")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "functions",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.KeyValue("oidc_sessions")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "oidc_sessions",
+			TTL:    10 * time.Minute,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.KeyValue("login_attempts")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "login_attempts",
+			TTL:    24 * time.Hour,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, bucket := range []string{"platform_config", "registry_credentials"} {
+		_, err = js.KeyValue(bucket)
+		if err == nats.ErrBucketNotFound {
+			_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+				Bucket: bucket,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// platform_meta holds the schema version of the stored data; locks
+	// expire so a lock held by a dead instance frees itself
+	_, err = js.KeyValue("platform_meta")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "platform_meta",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.KeyValue("locks")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "locks",
+			TTL:    30 * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.StreamInfo("AUDIT")
+	if err == nats.ErrStreamNotFound {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:       "AUDIT",
+			Subjects:   []string{"audit.>"},
+			Storage:    nats.FileStorage,
+			DenyDelete: true,
+			DenyPurge:  true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.KeyValue("usage")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "usage",
+			TTL:    400 * 24 * time.Hour,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Same retention as the USAGE stream, whose records can be redelivered
+	_, err = js.KeyValue("usage_executions")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "usage_executions",
+			TTL:    35 * 24 * time.Hour,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.StreamInfo("USAGE")
+	if err == nats.ErrStreamNotFound {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:       "USAGE",
+			Subjects:   []string{"usage.>"},
+			Storage:    nats.FileStorage,
+			MaxAge:     35 * 24 * time.Hour,
+			Duplicates: 10 * time.Minute,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = js.KeyValue("rate_limits")
+	if err == nats.ErrBucketNotFound {
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: "rate_limits",
+			TTL:    time.Hour,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Workers refresh their entry every few seconds; a missing entry means
+	// the worker is gone and its containers can be reaped
+	_, err = js.KeyValue("workers")
 	if err == nats.ErrBucketNotFound {
 		_, err = js.CreateKeyValue(&nats.KeyValueConfig{
-			Bucket: "user_functions",
+			Bucket: "workers",
+			TTL:    30 * time.Second,
 		})
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+
+	// Retention of the function logs can be tuned with LOG_RETENTION (a Go
+	// duration) and LOG_MAX_BYTES; the oldest lines are dropped first.
+	logsConfig := &nats.StreamConfig{
+		Name:       "LOGS",
+		Subjects:   []string{"logs.>"},
+		Storage:    nats.FileStorage,
+		MaxAge:     7 * 24 * time.Hour,
+		MaxBytes:   1 << 30,
+		MaxMsgSize: 64 * 1024,
+		Discard:    nats.DiscardOld,
+	}
+	if retention, err := time.ParseDuration(os.Getenv("LOG_RETENTION")); err == nil && retention > 0 {
+		logsConfig.MaxAge = retention
+	}
+	if maxBytes, err := strconv.ParseInt(os.Getenv("LOG_MAX_BYTES"), 10, 64); err == nil && maxBytes > 0 {
+		logsConfig.MaxBytes = maxBytes
+	}
+	_, err = js.StreamInfo("LOGS")
+	if err == nats.ErrStreamNotFound {
+		_, err = js.AddStream(logsConfig)
+	} else if err == nil {
+		_, err = js.UpdateStream(logsConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Execution records are kept for EXECUTION_RETENTION (30 days by default)
+	executionsConfig := &nats.StreamConfig{
+		Name:       "EXECUTIONS",
+		Subjects:   []string{"executions.>"},
+		Storage:    nats.FileStorage,
+		MaxAge:     30 * 24 * time.Hour,
+		Duplicates: 10 * time.Minute,
+	}
+	if retention, err := time.ParseDuration(os.Getenv("EXECUTION_RETENTION")); err == nil && retention > 0 {
+		executionsConfig.MaxAge = retention
+	}
+	_, err = js.StreamInfo("EXECUTIONS")
+	if err == nats.ErrStreamNotFound {
+		_, err = js.AddStream(executionsConfig)
+	} else if err == nil {
+		_, err = js.UpdateStream(executionsConfig)
+	}
+	return err
 }
 
 func GetJetStream() nats.JetStreamContext {
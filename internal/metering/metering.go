@@ -0,0 +1,91 @@
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// UsageSubject is the subject a worker publishes the usage record of an
+// invocation to, with the user and function encoded by KeyToken.
+func UsageSubject(record models.UsageRecord) string {
+	return fmt.Sprintf("usage.%s.%s", repository.KeyToken(record.User), repository.KeyToken(record.Function))
+}
+
+// PublishUsage sends a usage record to the USAGE stream. The execution ID is
+// used as message ID so a retried publish is not counted twice.
+func PublishUsage(js nats.JetStreamContext, record models.UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(UsageSubject(record), data, nats.MsgId(record.ExecutionID))
+	return err
+}
+
+const (
+	aggregatorName    = "usage-aggregator"
+	aggregatorAckWait = 30 * time.Second
+)
+
+// StartAggregator consumes the USAGE stream with a durable queue consumer,
+// so with several API replicas each record is aggregated once.
+func StartAggregator(js nats.JetStreamContext) (*nats.Subscription, error) {
+	usageRepository := repository.NewNATSUsageRepository(js)
+	return js.QueueSubscribe("usage.>", aggregatorName, func(msg *nats.Msg) {
+		var record models.UsageRecord
+		if err := json.Unmarshal(msg.Data, &record); err != nil {
+			slog.Error("registro de uso inválido", "error", err)
+			msg.Term()
+			return
+		}
+		if err := usageRepository.Add(record); err != nil {
+			slog.Error("error al agregar el uso", "user", record.User, "function", record.Function,
+				"execution_id", record.ExecutionID, "error", err)
+			msg.Nak()
+			return
+		}
+		msg.Ack()
+	}, nats.Durable(aggregatorName), nats.ManualAck(), nats.AckWait(aggregatorAckWait))
+}
+
+// SkipAggregated recreates the aggregator consumer after the last message
+// of the USAGE stream, so the records already counted in the "usage" bucket
+// (as after restoring both from a backup) are not aggregated again.
+func SkipAggregated(js nats.JetStreamContext) error {
+	info, err := js.StreamInfo("USAGE")
+	if err != nil {
+		return err
+	}
+	if err := js.DeleteConsumer("USAGE", aggregatorName); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		return err
+	}
+	_, err = js.AddConsumer("USAGE", &nats.ConsumerConfig{
+		Durable:        aggregatorName,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   aggregatorName,
+		DeliverPolicy:  nats.DeliverByStartSequencePolicy,
+		OptStartSeq:    info.State.LastSeq + 1,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        aggregatorAckWait,
+		FilterSubject:  "usage.>",
+	})
+	return err
+}
+
+// LoadQuota returns the platform quota configured through the environment.
+func LoadQuota() models.Quota {
+	quota := models.Quota{}
+	quota.MaxFunctions, _ = strconv.Atoi(os.Getenv("QUOTA_MAX_FUNCTIONS"))
+	quota.MaxInvocationsPerDay, _ = strconv.ParseInt(os.Getenv("QUOTA_MAX_INVOCATIONS_PER_DAY"), 10, 64)
+	quota.MaxGBSecondsPerMonth, _ = strconv.ParseFloat(os.Getenv("QUOTA_MAX_GB_SECONDS_PER_MONTH"), 64)
+	return quota
+}
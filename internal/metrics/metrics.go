@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// API server metrics
+var (
+	Invocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_invocations_total",
+		Help: "Invocations handled by the API server, by function and result (success, timeout, error).",
+	}, []string{"function", "status"})
+
+	InvocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "faas_invocation_duration_seconds",
+		Help:    "End-to-end invocation latency seen by the API server.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"function"})
+
+	InvocationsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "faas_invocations_in_flight",
+		Help: "Invocations published to NATS and still waiting for a worker reply.",
+	})
+)
+
+// Worker metrics
+var (
+	WorkerInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_worker_invocations_total",
+		Help: "Invocations run by the worker, by function and result.",
+	}, []string{"function", "status"})
+
+	ContainerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "faas_container_duration_seconds",
+		Help:    "Time from container start until it exits.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"function"})
+
+	ContainerStarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "faas_container_starts_total",
+		Help: "Container starts, cold when the image had to be downloaded first.",
+	}, []string{"function", "start"})
+
+	ImagePullDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "faas_image_pull_duration_seconds",
+		Help:    "Duration of image pulls.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	RunningContainers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "faas_running_containers",
+		Help: "Function containers currently running on the worker.",
+	})
+)
+
+func RegisterAPI(nc *nats.Conn) {
+	prometheus.MustRegister(Invocations, InvocationDuration, InvocationsInFlight)
+	registerNATS(nc)
+}
+
+// RegisterWorker registers the worker metrics. queueDepth reports the
+// messages received from the subscription but not yet processed.
+func RegisterWorker(nc *nats.Conn, queueDepth func() float64) {
+	prometheus.MustRegister(WorkerInvocations, ContainerDuration, ContainerStarts, ImagePullDuration, RunningContainers)
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "faas_worker_queue_depth",
+		Help: "Invocation messages pending in the worker subscription.",
+	}, queueDepth))
+	registerNATS(nc)
+}
+
+func registerNATS(nc *nats.Conn) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "faas_nats_connected",
+		Help: "1 if the NATS connection is established.",
+	}, func() float64 {
+		if nc.IsConnected() {
+			return 1
+		}
+		return 0
+	}))
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "faas_nats_reconnects_total",
+		Help: "NATS reconnections since start.",
+	}, func() float64 {
+		return float64(nc.Stats().Reconnects)
+	}))
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Since returns the seconds elapsed since start, for histograms.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
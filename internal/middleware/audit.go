@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"faas-project/internal/logging"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Audit records the action to the audit stream once the handler has
+// responded. It must run inside JWTMiddleware so the actor is known.
+func Audit(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor := Username(r)
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+			r.Header.Set(logging.RequestIDHeader, requestID)
+		}
+		target := auditTarget(r)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		event := models.AuditEvent{
+			Time:      time.Now().UTC(),
+			Actor:     actor,
+			Action:    action,
+			Target:    target,
+			SourceIP:  ClientIP(r),
+			Status:    recorder.status,
+			Result:    "success",
+			RequestID: requestID,
+		}
+		if event.Status == 0 {
+			event.Status = http.StatusOK
+		}
+		if event.Status >= 400 {
+			event.Result = "error"
+		}
+		if err := repository.GetAuditRepository().Record(event); err != nil {
+			logging.FromContext(r.Context()).Error("error al registrar el evento de auditoría", "action", action, "error", err)
+		}
+	}
+}
+
+// auditTarget finds the function or user an action refers to: the
+// /function/{name} path (also for its subresources), the username query parameter or the "name"/
+// "username" field of a JSON body, which is restored for the handler.
+func auditTarget(r *http.Request) string {
+	if name := strings.TrimPrefix(r.URL.Path, "/function/"); name != r.URL.Path && name != "" {
+		name, _, _ = strings.Cut(name, "/")
+		return name
+	}
+	if username := r.URL.Query().Get("username"); username != "" {
+		return username
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+	var fields struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	if fields.Name != "" {
+		return fields.Name
+	}
+	return fields.Username
+}
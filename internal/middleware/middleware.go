@@ -1,9 +1,16 @@
 package middleware
 
 import (
-	"encoding/json"
+	"context"
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/auth"
+	"faas-project/internal/i18n"
+	"faas-project/internal/logging"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -17,39 +24,133 @@ func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// Extract the token from the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			JSONResponse(w, http.StatusUnauthorized, "Authorization header missing")
+			apierror.Write(w, http.StatusUnauthorized, "token_missing", i18n.Message(i18n.FromRequest(r), "token_missing"))
 			return
 		}
 
 		// Token should be in the format "Bearer <token>"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			JSONResponse(w, http.StatusUnauthorized, "Invalid Authorization header format")
+			apierror.Write(w, http.StatusUnauthorized, "token_malformed", i18n.Message(i18n.FromRequest(r), "token_malformed"))
 			return
 		}
 
 		tokenString := tokenParts[1]
 
 		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return JwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			JSONResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+		username, err := ParseToken(tokenString)
+		if err != nil {
+			apierror.Write(w, http.StatusUnauthorized, "token_invalid", i18n.Message(i18n.FromRequest(r), "token_invalid"))
 			return
 		}
 
 		// Call the next handler if the token is valid
-		next(w, r)
+		ctx := context.WithValue(r.Context(), usernameKey{}, username)
+		next(w, r.WithContext(logging.With(ctx, "user", username)))
+	}
+}
+
+type usernameKey struct{}
+
+// Username returns the user authenticated by JWTMiddleware, or "" outside it.
+func Username(r *http.Request) string {
+	username, _ := r.Context().Value(usernameKey{}).(string)
+	return username
+}
+
+// ParseToken validates a locally issued token or, when an OIDC issuer is
+// configured, a bearer token from the IdP, and returns the username.
+func ParseToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return JwtSecret, nil
+	})
+	if err == nil && token.Valid {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			if username, ok := claims["sub"].(string); ok {
+				return username, nil
+			}
+		}
+		return "", fmt.Errorf("token without subject")
+	}
+	if auth.OIDCEnabled() {
+		identity, oidcErr := auth.GetOIDCProvider().Verify(tokenString)
+		if oidcErr == nil {
+			return identity.Username, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("invalid token")
+	}
+	return "", err
+}
+
+// ClientIP returns the address of the caller. The headers set by proxies
+// are only read when the connection comes from one in TRUSTED_PROXIES;
+// anyone else could send a different value on every request. From a
+// trusted proxy, X-Real-IP (set by APISIX from the connection) is used
+// first, and otherwise X-Forwarded-For is read from the right, skipping
+// the trusted proxies: its left entries are whatever the client sent.
+func ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !trustedProxy(remote) {
+		return remote
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !trustedProxy(hop) {
+			return hop
+		}
+	}
+	return remote
+}
+
+// trustedProxies are the networks in TRUSTED_PROXIES (comma separated
+// addresses or CIDRs) whose X-Forwarded-For entries are believed.
+var trustedProxies = loadTrustedProxies()
+
+func loadTrustedProxies() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			slog.Error("TRUSTED_PROXIES inválido", "entry", entry, "error", err)
+			continue
+		}
+		networks = append(networks, network)
 	}
+	return networks
 }
 
-// Send JSON responses
-func JSONResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{"message": message})
+func trustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	for _, network := range trustedProxies {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	trustedProxies = []*net.IPNet{proxies}
+	t.Cleanup(func() { trustedProxies = loadTrustedProxies() })
+
+	tests := []struct {
+		name         string
+		remote       string
+		realIP       string
+		forwardedFor string
+		want         string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "direct ignores X-Real-IP", remote: "203.0.113.7:5000", realIP: "198.51.100.1", want: "203.0.113.7"},
+		{name: "direct ignores X-Forwarded-For", remote: "203.0.113.7:5000", forwardedFor: "198.51.100.1", want: "203.0.113.7"},
+		{name: "proxy with X-Real-IP", remote: "10.0.0.5:5000", realIP: "198.51.100.1", forwardedFor: "192.0.2.9", want: "198.51.100.1"},
+		{name: "proxy with invalid X-Real-IP", remote: "10.0.0.5:5000", realIP: "nope", want: "10.0.0.5"},
+		{name: "proxy with X-Forwarded-For", remote: "10.0.0.5:5000", forwardedFor: "192.0.2.9, 198.51.100.1", want: "198.51.100.1"},
+		{name: "skips trusted hops", remote: "10.0.0.5:5000", forwardedFor: "192.0.2.9, 198.51.100.1, 10.0.0.6", want: "198.51.100.1"},
+		{name: "stops at an invalid hop", remote: "10.0.0.5:5000", forwardedFor: "192.0.2.9, garbage, 10.0.0.6", want: "10.0.0.5"},
+		{name: "proxy without headers", remote: "10.0.0.5:5000", want: "10.0.0.5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remote
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			if got := ClientIP(r); got != test.want {
+				t.Errorf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/api/router"
+	"faas-project/internal/i18n"
+	"faas-project/internal/logging"
+	"faas-project/internal/models"
+	"faas-project/internal/repository"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rate limit scopes. Each scope is limited by several token buckets: per
+// user, per API key, per client IP and, for invocations, per function.
+const (
+	ScopeAuth   = "auth"
+	ScopeInvoke = "invoke"
+	ScopeAPI    = "api"
+)
+
+// RateLimitPlan maps "<scope>.<key kind>" (e.g. "invoke.function") to its limit.
+type RateLimitPlan map[string]models.RateLimit
+
+var defaultRateLimitPlans = map[string]RateLimitPlan{
+	"default": {
+		"auth.ip":         {Rate: 0.2, Burst: 10},
+		"invoke.user":     {Rate: 5, Burst: 20},
+		"invoke.apikey":   {Rate: 5, Burst: 20},
+		"invoke.ip":       {Rate: 10, Burst: 40},
+		"invoke.function": {Rate: 5, Burst: 20},
+		"api.user":        {Rate: 10, Burst: 50},
+		"api.apikey":      {Rate: 10, Burst: 50},
+		"api.ip":          {Rate: 20, Burst: 100},
+	},
+}
+
+// LoadRateLimitPlans returns the default plans overridden by the JSON in
+// RATE_LIMIT_PLANS, e.g. {"pro": {"invoke.user": {"rate": 50, "burst": 200}}}.
+// Plans other than "default" inherit every limit they do not set.
+func LoadRateLimitPlans() map[string]RateLimitPlan {
+	plans := map[string]RateLimitPlan{}
+	for name, plan := range defaultRateLimitPlans {
+		plans[name] = copyPlan(plan)
+	}
+	var configured map[string]RateLimitPlan
+	if raw := os.Getenv("RATE_LIMIT_PLANS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &configured); err != nil {
+			slog.Error("RATE_LIMIT_PLANS inválido", "error", err)
+		}
+	}
+	if plan, ok := configured["default"]; ok {
+		for key, limit := range plan {
+			plans["default"][key] = limit
+		}
+	}
+	for name, plan := range configured {
+		if name == "default" {
+			continue
+		}
+		merged := copyPlan(plans["default"])
+		for key, limit := range plan {
+			merged[key] = limit
+		}
+		plans[name] = merged
+	}
+	return plans
+}
+
+func copyPlan(plan RateLimitPlan) RateLimitPlan {
+	copied := RateLimitPlan{}
+	for key, limit := range plan {
+		copied[key] = limit
+	}
+	return copied
+}
+
+var rateLimitPlans = LoadRateLimitPlans()
+
+// planUsers is where RateLimit looks up the plan of the caller. Until main
+// sets it every caller gets the default plan.
+var planUsers repository.UserRepository
+
+// SetUserRepository sets the users RateLimit reads the plans from.
+func SetUserRepository(users repository.UserRepository) {
+	planUsers = users
+}
+
+// validAPIKey reports whether an X-API-Key header is a key issued by the
+// platform. Until it is set no key is trusted and those requests are only
+// limited by user and IP, so a client cannot get fresh buckets by sending a
+// different header on every request.
+var validAPIKey func(key string) bool
+
+// SetAPIKeyValidator sets the check an X-API-Key must pass to get its own
+// buckets.
+func SetAPIKeyValidator(valid func(key string) bool) {
+	validAPIKey = valid
+}
+
+// rateLimitKeys returns the bucket of each key kind that limits r.
+func rateLimitKeys(scope, username string, r *http.Request) map[string]string {
+	keys := map[string]string{"ip": strings.ReplaceAll(ClientIP(r), ":", "-")}
+	if username != "" {
+		keys["user"] = repository.KeyToken(username)
+	}
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" && validAPIKey != nil && validAPIKey(apiKey) {
+		sum := sha256.Sum256([]byte(apiKey))
+		keys["apikey"] = hex.EncodeToString(sum[:8])
+	}
+	if scope == ScopeInvoke {
+		// The parameter of the matched route, whatever prefix it is served under
+		if name := router.Param(r, "name"); name != "" {
+			// Function names are only unique within the caller's namespace
+			keys["function"] = repository.KeyToken(username) + "." + repository.KeyToken(name)
+		}
+	}
+	return keys
+}
+
+// RateLimit applies the token buckets of scope to the request and answers
+// 429 when any of them is empty. The RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers describe the most restrictive bucket. If the
+// shared store is unavailable requests are let through.
+func RateLimit(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := Username(r)
+		plan := rateLimitPlans["default"]
+		if username != "" && planUsers != nil {
+			if user, err := planUsers.GetByUsername(username); err == nil && user.Plan != "" {
+				if userPlan, ok := rateLimitPlans[user.Plan]; ok {
+					plan = userPlan
+				}
+			}
+		}
+
+		keys := rateLimitKeys(scope, username, r)
+
+		allowed := true
+		remaining, limitValue := math.MaxFloat64, 0
+		var reset, retryAfter float64
+		for kind, id := range keys {
+			limit, ok := plan[scope+"."+kind]
+			if !ok || limit.Rate <= 0 {
+				continue
+			}
+			ok, bucket, err := repository.GetRateLimitRepository().Take(scope+"."+kind+"."+id, limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("error en el rate limit", "scope", scope, "key", kind, "error", err)
+				continue
+			}
+			if !ok {
+				allowed = false
+				retryAfter = math.Max(retryAfter, (1-bucket.Tokens)/limit.Rate)
+			}
+			if bucket.Tokens < remaining {
+				remaining, limitValue = bucket.Tokens, limit.Burst
+				reset = (float64(limit.Burst) - bucket.Tokens) / limit.Rate
+			}
+		}
+
+		if limitValue > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limitValue))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset))))
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter))))
+			apierror.Write(w, http.StatusTooManyRequests, "rate_limited", i18n.Message(i18n.FromRequest(r), "rate_limited"))
+			return
+		}
+		next(w, r)
+	}
+}
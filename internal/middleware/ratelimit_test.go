@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"faas-project/internal/api/router"
+	"faas-project/internal/repository"
+)
+
+func TestRateLimitKeys(t *testing.T) {
+	var keys map[string]string
+	rt := router.New()
+	rt.Handle(router.Route{Method: http.MethodPost, Path: "/function/{name}", Handler: func(w http.ResponseWriter, r *http.Request) {
+		keys = rateLimitKeys(ScopeInvoke, "alice", r)
+	}})
+	mux := http.NewServeMux()
+	mux.Handle("/", rt.Handler(false))
+	mux.Handle("/v1/", http.StripPrefix("/v1", rt.Handler(true)))
+	t.Cleanup(func() { validAPIKey = nil })
+
+	function := repository.KeyToken("alice") + "." + repository.KeyToken("hello")
+	tests := []struct {
+		name      string
+		path      string
+		apiKey    string
+		validator func(string) bool
+		want      map[string]string
+	}{
+		{name: "function", path: "/function/hello",
+			want: map[string]string{"ip": "192.0.2.1", "user": "alice", "function": function}},
+		{name: "versioned function", path: "/v1/function/hello",
+			want: map[string]string{"ip": "192.0.2.1", "user": "alice", "function": function}},
+		{name: "unvalidated API key", path: "/function/hello", apiKey: "key",
+			want: map[string]string{"ip": "192.0.2.1", "user": "alice", "function": function}},
+		{name: "rejected API key", path: "/function/hello", apiKey: "key", validator: func(string) bool { return false },
+			want: map[string]string{"ip": "192.0.2.1", "user": "alice", "function": function}},
+		{name: "valid API key", path: "/function/hello", apiKey: "key", validator: func(key string) bool { return key == "key" },
+			want: map[string]string{"ip": "192.0.2.1", "user": "alice", "function": function, "apikey": "2c70e12b7a0646f9"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetAPIKeyValidator(test.validator)
+			keys = nil
+			r := httptest.NewRequest(http.MethodPost, test.path, nil)
+			r.RemoteAddr = "192.0.2.1:5000"
+			if test.apiKey != "" {
+				r.Header.Set("X-API-Key", test.apiKey)
+			}
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+			if !reflect.DeepEqual(keys, test.want) {
+				t.Errorf("keys = %v, want %v", keys, test.want)
+			}
+		})
+	}
+}
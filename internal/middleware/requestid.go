@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"faas-project/internal/logging"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestID assigns every request an ID (reusing a valid incoming
+// X-Request-ID), returns it in the response, stores a request-scoped logger
+// in the context and writes one access log line per request.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.New().String()
+		}
+		r.Header.Set(logging.RequestIDHeader, requestID)
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		// Probes would flood the log at info level
+		level := slog.LevelInfo
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics" {
+			level = slog.LevelDebug
+		}
+		logging.FromContext(ctx).Log(ctx, level, "petición HTTP",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_ip", ClientIP(r),
+		)
+	})
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AuditEvent records one authenticated control-plane action.
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	SourceIP  string    `json:"sourceIp"`
+	Status    int       `json:"status"`
+	Result    string    `json:"result"`
+	RequestID string    `json:"requestId"`
+}
+
+// AuditFilter selects events in GET /audit. Zero values match everything.
+type AuditFilter struct {
+	Actor    string
+	Function string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
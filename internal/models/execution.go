@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Execution is the record a worker stores for every invocation it handles.
+// Version is the Function.Version that ran and ImageID the local ID of the
+// image.
+type Execution struct {
+	ID          string        `json:"id"`
+	Namespace   string        `json:"namespace"`
+	Function    string        `json:"function"`
+	Image       string        `json:"image"`
+	Version     int64         `json:"version,omitempty"`
+	ImageID     string        `json:"imageId,omitempty"`
+	ImageDigest string        `json:"imageDigest,omitempty"`
+	Caller      string        `json:"caller"`
+	ParamDigest string        `json:"paramDigest"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Duration    time.Duration `json:"duration"`
+	ExitCode    int64         `json:"exitCode"`
+	Status      string        `json:"status"`
+	WorkerID    string        `json:"workerId"`
+}
+
+// UnmarshalJSON also reads the records of older workers, whose version was
+// the image ID.
+func (e *Execution) UnmarshalJSON(data []byte) error {
+	type plain Execution
+	var record struct {
+		plain
+		Version json.RawMessage `json:"version,omitempty"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*e = Execution(record.plain)
+	if len(record.Version) == 0 {
+		return nil
+	}
+	var imageID string
+	if err := json.Unmarshal(record.Version, &imageID); err == nil {
+		if e.ImageID == "" {
+			e.ImageID = imageID
+		}
+		return nil
+	}
+	return json.Unmarshal(record.Version, &e.Version)
+}
+
+// ExecutionFilter selects records in GET /function/{name}/executions. Zero
+// values match everything. After is the pagination cursor.
+type ExecutionFilter struct {
+	Namespace string
+	Function  string
+	Caller    string
+	Status    string
+	Since     time.Time
+	Until     time.Time
+	After     uint64
+	Limit     int
+}
+
+// ExecutionStats summarises the executions of a function over a window.
+type ExecutionStats struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Invocations int64     `json:"invocations"`
+	Errors      int64     `json:"errors"`
+	Timeouts    int64     `json:"timeouts"`
+	ErrorRate   float64   `json:"errorRate"`
+	P50Ms       int64     `json:"p50Ms"`
+	P95Ms       int64     `json:"p95Ms"`
+	P99Ms       int64     `json:"p99Ms"`
+}
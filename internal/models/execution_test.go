@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExecutionVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      string
+		wantVersion int64
+		wantImageID string
+	}{
+		{"current", `{"id":"a","version":3,"imageId":"sha256:abc"}`, 3, "sha256:abc"},
+		{"legacy image id", `{"id":"a","version":"sha256:abc"}`, 0, "sha256:abc"},
+		{"legacy empty", `{"id":"a","version":""}`, 0, ""},
+		{"no version", `{"id":"a"}`, 0, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var execution Execution
+			if err := json.Unmarshal([]byte(test.record), &execution); err != nil {
+				t.Fatalf("Unmarshal(%s): %v", test.record, err)
+			}
+			if execution.ID != "a" || execution.Version != test.wantVersion || execution.ImageID != test.wantImageID {
+				t.Errorf("Unmarshal(%s) = id %q version %d imageId %q, want a %d %q",
+					test.record, execution.ID, execution.Version, execution.ImageID, test.wantVersion, test.wantImageID)
+			}
+		})
+	}
+}
+
+func TestExecutionRoundTrip(t *testing.T) {
+	want := Execution{ID: "a", Function: "f", Version: 7, ImageID: "sha256:abc", ExitCode: 1}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Execution
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != want.ID || got.Function != want.Function || got.Version != want.Version ||
+		got.ImageID != want.ImageID || got.ExitCode != want.ExitCode {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
@@ -1,8 +1,41 @@
 package models
 
+import "time"
+
+// Function is a stored function definition. Version starts at 1 and grows
+// with every change of the definition; functions registered before it was
+// tracked have version 0 and no timestamps until they are next updated.
 type Function struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	OwnerId string `json:"ownerId"`
-	Image   string `json:"image"`
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	OwnerId   string            `json:"ownerId"`
+	Image     string            `json:"image"`
+	MemoryMB  int64             `json:"memoryMB,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Security  *SecurityProfile  `json:"security,omitempty"`
+	Version   int64             `json:"version"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// FunctionStatus is the detail of a function returned by GET
+// /function/{name}. ImageDigest is the digest the image is pinned to or,
+// otherwise, the one the last invocation of the current image ran.
+type FunctionStatus struct {
+	Function       Function   `json:"function"`
+	ImageDigest    string     `json:"imageDigest,omitempty"`
+	LastInvocation *Execution `json:"lastInvocation,omitempty"`
+}
+
+// SecurityProfile overrides the platform sandbox defaults for a single
+// function. Empty fields keep the platform default.
+type SecurityProfile struct {
+	ReadOnlyRootFS  *bool    `json:"readOnlyRootFs,omitempty"`
+	TmpfsSize       string   `json:"tmpfsSize,omitempty"`
+	AddCapabilities []string `json:"addCapabilities,omitempty"`
+	User            string   `json:"user,omitempty"`
+	SeccompProfile  string   `json:"seccompProfile,omitempty"`
+	Runtime         string   `json:"runtime,omitempty"`
+	NetworkMode     string   `json:"networkMode,omitempty"`
 }
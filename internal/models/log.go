@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LogEntry is one line written by a function container.
+type LogEntry struct {
+	Time        time.Time `json:"time"`
+	Namespace   string    `json:"namespace"`
+	Function    string    `json:"function"`
+	ExecutionID string    `json:"executionId"`
+	Stream      string    `json:"stream"`
+	Line        string    `json:"line"`
+}
+
+// LogFilter selects entries in GET /function/{name}/logs. Zero values match
+// everything.
+type LogFilter struct {
+	Namespace   string
+	Function    string
+	ExecutionID string
+	Since       time.Time
+	Limit       int
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// ManifestVersion is the manifest format written by GET /export. Imports of
+// a newer version are rejected.
+const ManifestVersion = 1
+
+// Manifest is the portable description of the functions of a namespace.
+// Registry credentials are listed by registry, never with their secrets.
+type Manifest struct {
+	Version             int            `json:"version"`
+	Namespace           string         `json:"namespace,omitempty"`
+	ExportedAt          time.Time      `json:"exportedAt"`
+	Functions           []FunctionSpec `json:"functions"`
+	RegistryCredentials []string       `json:"registryCredentials,omitempty"`
+}
+
+// FunctionSpec is a function definition without the fields that belong to
+// the environment it is stored in (ID and owner). SecretEnv names variables
+// whose values are not in the manifest: an import keeps the value the
+// target already has. Version is informational; imports ignore it.
+type FunctionSpec struct {
+	Name      string            `json:"name"`
+	Version   int64             `json:"version,omitempty"`
+	Image     string            `json:"image"`
+	MemoryMB  int64             `json:"memoryMB,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	SecretEnv []string          `json:"secretEnv,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Security  *SecurityProfile  `json:"security,omitempty"`
+}
+
+// FaasFileVersion is the apiVersion of faas.yaml files.
+const FaasFileVersion = "faas/v1"
+
+// FaasFile is the faas.yaml format: the desired functions of a namespace,
+// kept in version control and reconciled with POST /apply.
+type FaasFile struct {
+	APIVersion string               `json:"apiVersion"`
+	Functions  []FunctionDefinition `json:"functions"`
+}
+
+type FunctionDefinition struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	Limits   FunctionLimits    `json:"limits,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Security *SecurityProfile  `json:"security,omitempty"`
+}
+
+type FunctionLimits struct {
+	MemoryMB int64 `json:"memoryMB,omitempty"`
+}
+
+func (d FunctionDefinition) Spec() FunctionSpec {
+	return FunctionSpec{
+		Name:     d.Name,
+		Image:    d.Image,
+		MemoryMB: d.Limits.MemoryMB,
+		Env:      d.Env,
+		Labels:   d.Labels,
+		Security: d.Security,
+	}
+}
+
+// Actions of a ManifestChange.
+const (
+	ChangeCreate    = "create"
+	ChangeUpdate    = "update"
+	ChangeDelete    = "delete"
+	ChangeUnchanged = "unchanged"
+)
+
+// ManifestChange is what importing a manifest does, or would do, to one
+// function.
+type ManifestChange struct {
+	Action   string   `json:"action"`
+	Function string   `json:"function"`
+	Fields   []string `json:"fields,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// ImportResult describes an import or an apply. MissingCredentials lists
+// the registries the manifest needs credentials for that the namespace does
+// not have, and MissingEnv the secretEnv variables ("function/VARIABLE")
+// with no value in the namespace, which are left unset.
+type ImportResult struct {
+	Namespace          string           `json:"namespace"`
+	DryRun             bool             `json:"dryRun"`
+	Changes            []ManifestChange `json:"changes"`
+	MissingCredentials []string         `json:"missingCredentials,omitempty"`
+	MissingEnv         []string         `json:"missingEnv,omitempty"`
+}
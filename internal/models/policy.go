@@ -0,0 +1,19 @@
+package models
+
+// ImagePolicy is the admin-managed set of rules every function image must
+// satisfy. Registries are matched by host (e.g. "docker.io", "ghcr.io").
+type ImagePolicy struct {
+	AllowedRegistries []string `json:"allowedRegistries"`
+	RequireDigest     bool     `json:"requireDigest"`
+	DeniedImages      []string `json:"deniedImages"`
+	MaxImageSizeMB    int64    `json:"maxImageSizeMB"`
+}
+
+// RegistryCredential is used by the workers to pull private images for the
+// functions of a namespace.
+type RegistryCredential struct {
+	Namespace string `json:"namespace"`
+	Registry  string `json:"registry"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
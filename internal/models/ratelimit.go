@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RateLimit configures a token bucket: Rate tokens are added per second up
+// to Burst. A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// TokenBucket is the shared state of one rate limit key.
+type TokenBucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
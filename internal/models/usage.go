@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// UsageRecord is emitted by a worker for every invocation it runs.
+type UsageRecord struct {
+	ExecutionID   string        `json:"executionId"`
+	Function      string        `json:"function"`
+	User          string        `json:"user"`
+	Start         time.Time     `json:"start"`
+	Duration      time.Duration `json:"duration"`
+	MemoryLimitMB int64         `json:"memoryLimitMB"`
+	ExitCode      int64         `json:"exitCode"`
+	Status        string        `json:"status"`
+}
+
+// GBSeconds is the billed consumption of the record.
+func (u UsageRecord) GBSeconds() float64 {
+	return float64(u.MemoryLimitMB) / 1024 * u.Duration.Seconds()
+}
+
+// UsageCounters aggregates usage records for one user over a period.
+type UsageCounters struct {
+	Invocations      int64   `json:"invocations"`
+	Errors           int64   `json:"errors"`
+	ContainerSeconds float64 `json:"containerSeconds"`
+	GBSeconds        float64 `json:"gbSeconds"`
+}
+
+// Quota limits what a user can consume. Zero means unlimited.
+type Quota struct {
+	MaxFunctions         int     `json:"maxFunctions"`
+	MaxInvocationsPerDay int64   `json:"maxInvocationsPerDay"`
+	MaxGBSecondsPerMonth float64 `json:"maxGBSecondsPerMonth"`
+}
@@ -1,7 +1,33 @@
 package models
 
+import "time"
+
 type User struct {
-	ID string `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
\ No newline at end of file
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Password    string    `json:"password"`
+	Roles       []string  `json:"roles,omitempty"`
+	Plan        string    `json:"plan,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	// OIDCIssuer and OIDCSubject link the accounts created by the OIDC
+	// login to their IdP subject.
+	OIDCIssuer  string `json:"oidcIssuer,omitempty"`
+	OIDCSubject string `json:"oidcSubject,omitempty"`
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginAttempts counts consecutive failed logins for a username or client IP.
+type LoginAttempts struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"encoding/json"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSAuditRepository appends audit events to the AUDIT stream under
+// "audit.<actor>.<action>". The stream denies deletes and purges.
+type NATSAuditRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSAuditRepository(js nats.JetStreamContext) *NATSAuditRepository {
+	return &NATSAuditRepository{js: js}
+}
+
+func (r *NATSAuditRepository) Record(event models.AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = r.js.Publish(fmt.Sprintf("audit.%s.%s", KeyToken(event.Actor), KeyToken(event.Action)), data)
+	return err
+}
+
+// Query replays the stream from filter.Since and returns the matching
+// events in order, at most filter.Limit of them.
+func (r *NATSAuditRepository) Query(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	subject := "audit.>"
+	if filter.Actor != "" {
+		subject = fmt.Sprintf("audit.%s.>", KeyToken(filter.Actor))
+	}
+	opts := []nats.SubOpt{nats.OrderedConsumer()}
+	if filter.Since.IsZero() {
+		opts = append(opts, nats.DeliverAll())
+	} else {
+		opts = append(opts, nats.StartTime(filter.Since))
+	}
+	sub, err := r.js.SubscribeSync(subject, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	events := []models.AuditEvent{}
+	if isEmpty(sub) {
+		return events, nil
+	}
+	for filter.Limit <= 0 || len(events) < filter.Limit {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err == nats.ErrTimeout {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var event models.AuditEvent
+		if err := json.Unmarshal(msg.Data, &event); err == nil {
+			if !filter.Until.IsZero() && event.Time.After(filter.Until) {
+				break
+			}
+			// Restored events are stored later than they happened
+			if (filter.Function == "" || event.Target == filter.Function) && !event.Time.Before(filter.Since) {
+				events = append(events, event)
+			}
+		}
+		if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+			break
+		}
+	}
+	return events, nil
+}
+
+func GetAuditRepository() *NATSAuditRepository {
+	return NewNATSAuditRepository(message.GetJetStream())
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var ErrExecutionNotFound = errors.New("ejecución no encontrada")
+
+// NATSExecutionRepository stores execution records in the EXECUTIONS stream
+// under "executions.<namespace>.<function>.<id>".
+type NATSExecutionRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSExecutionRepository(js nats.JetStreamContext) *NATSExecutionRepository {
+	return &NATSExecutionRepository{js: js}
+}
+
+func (r *NATSExecutionRepository) Record(execution models.Execution) error {
+	data, err := json.Marshal(execution)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("executions.%s.%s.%s", KeyToken(execution.Namespace), KeyToken(execution.Function), KeyToken(execution.ID))
+	_, err = r.js.Publish(subject, data, nats.MsgId(execution.ID))
+	return err
+}
+
+// Query returns the matching records in the order they were stored, at most
+// filter.Limit of them. next is the cursor for the following page, or 0 if
+// there are no more records.
+func (r *NATSExecutionRepository) Query(filter models.ExecutionFilter) (executions []models.Execution, next uint64, err error) {
+	opts := []nats.SubOpt{nats.OrderedConsumer()}
+	switch {
+	case filter.After > 0:
+		opts = append(opts, nats.StartSequence(filter.After+1))
+	case !filter.Since.IsZero():
+		opts = append(opts, nats.StartTime(filter.Since))
+	default:
+		opts = append(opts, nats.DeliverAll())
+	}
+	sub, err := r.js.SubscribeSync(fmt.Sprintf("executions.%s.%s.*", KeyToken(filter.Namespace), KeyToken(filter.Function)), opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer sub.Unsubscribe()
+
+	executions = []models.Execution{}
+	if isEmpty(sub) {
+		return executions, 0, nil
+	}
+	for {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err == nats.ErrTimeout {
+			return executions, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, 0, err
+		}
+		var execution models.Execution
+		if err := json.Unmarshal(msg.Data, &execution); err == nil && matchExecution(filter, execution) {
+			executions = append(executions, execution)
+		}
+		if meta.NumPending == 0 {
+			return executions, 0, nil
+		}
+		if filter.Limit > 0 && len(executions) >= filter.Limit {
+			return executions, meta.Sequence.Stream, nil
+		}
+	}
+}
+
+func matchExecution(filter models.ExecutionFilter, execution models.Execution) bool {
+	if filter.Caller != "" && execution.Caller != filter.Caller {
+		return false
+	}
+	if filter.Status != "" && execution.Status != filter.Status {
+		return false
+	}
+	if !filter.Since.IsZero() && execution.Start.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && execution.Start.After(filter.Until) {
+		return false
+	}
+	return true
+}
+
+// Last returns the most recent record of a function, or
+// ErrExecutionNotFound if it has never been invoked.
+func (r *NATSExecutionRepository) Last(namespace, function string) (models.Execution, error) {
+	var execution models.Execution
+	msg, err := r.js.GetLastMsg("EXECUTIONS", fmt.Sprintf("executions.%s.%s.*", KeyToken(namespace), KeyToken(function)))
+	if err == nats.ErrMsgNotFound {
+		return execution, ErrExecutionNotFound
+	}
+	if err != nil {
+		return execution, err
+	}
+	err = json.Unmarshal(msg.Data, &execution)
+	return execution, err
+}
+
+// Purge removes the records of a deleted function.
+func (r *NATSExecutionRepository) Purge(namespace, function string) error {
+	return r.js.PurgeStream("EXECUTIONS", &nats.StreamPurgeRequest{
+		Subject: fmt.Sprintf("executions.%s.%s.>", KeyToken(namespace), KeyToken(function)),
+	})
+}
+
+func GetExecutionRepository() *NATSExecutionRepository {
+	return NewNATSExecutionRepository(message.GetJetStream())
+}
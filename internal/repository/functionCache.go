@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"faas-project/internal/models"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// FunctionCache mirrors the "functions" bucket in memory so lookups on the
+// invocation path do not hit JetStream. It is kept up to date by a watch on
+// "fn.>"; writes made through this process are applied right away as well.
+type FunctionCache struct {
+	mu          sync.RWMutex
+	entries     map[string]cachedFunction
+	byNamespace map[string]map[string]struct{}
+	synced      atomic.Bool
+}
+
+type cachedFunction struct {
+	function models.Function
+	revision uint64
+	deleted  bool
+}
+
+var functionCache *FunctionCache
+
+// StartFunctionCache loads the functions and keeps watching them. Until the
+// initial load is done the repository reads from JetStream directly.
+func StartFunctionCache(js nats.JetStreamContext) error {
+	kv, err := js.KeyValue("functions")
+	if err != nil {
+		return err
+	}
+	cache := &FunctionCache{
+		entries:     map[string]cachedFunction{},
+		byNamespace: map[string]map[string]struct{}{},
+	}
+	watcher, err := kv.Watch("fn.>")
+	if err != nil {
+		return err
+	}
+	functionCache = cache
+	go cache.run(kv, watcher)
+	return nil
+}
+
+func (c *FunctionCache) run(kv nats.KeyValue, watcher nats.KeyWatcher) {
+	for {
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				c.synced.Store(true)
+				continue
+			}
+			switch entry.Operation() {
+			case nats.KeyValuePut:
+				var function models.Function
+				if err := decodeRecord(entry.Value(), &function); err != nil {
+					slog.Error("función con formato inválido en el bucket", "key", entry.Key(), "error", err)
+					continue
+				}
+				c.put(entry.Key(), function, entry.Revision())
+			case nats.KeyValueDelete, nats.KeyValuePurge:
+				c.delete(entry.Key(), entry.Revision())
+			}
+		}
+
+		// The watcher stopped: serve from JetStream until it is back
+		c.synced.Store(false)
+		slog.Warn("watch de funciones interrumpido, reintentando")
+		for {
+			time.Sleep(time.Second)
+			var err error
+			if watcher, err = kv.Watch("fn.>"); err == nil {
+				break
+			}
+		}
+	}
+}
+
+func (c *FunctionCache) ready() bool {
+	return c.synced.Load()
+}
+
+func namespaceOfKey(key string) string {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// put and delete ignore changes older than the cached revision, which
+// happens when a local write is applied before the watch delivers it.
+func (c *FunctionCache) put(key string, function models.Function, revision uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.entries[key]; ok && cached.revision >= revision {
+		return
+	}
+	c.entries[key] = cachedFunction{function: function, revision: revision}
+	namespace := namespaceOfKey(key)
+	if c.byNamespace[namespace] == nil {
+		c.byNamespace[namespace] = map[string]struct{}{}
+	}
+	c.byNamespace[namespace][key] = struct{}{}
+}
+
+func (c *FunctionCache) delete(key string, revision uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.entries[key]; ok && cached.revision > revision {
+		return
+	}
+	c.entries[key] = cachedFunction{revision: revision, deleted: true}
+	delete(c.byNamespace[namespaceOfKey(key)], key)
+}
+
+func (c *FunctionCache) get(key string) (models.Function, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.entries[key]
+	if !ok || cached.deleted {
+		return models.Function{}, false
+	}
+	return cached.function, true
+}
+
+func (c *FunctionCache) list(namespace string) []models.Function {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	functions := []models.Function{}
+	for key := range c.byNamespace[KeyToken(namespace)] {
+		functions = append(functions, c.entries[key].function)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+	return functions
+}
@@ -1,12 +1,21 @@
 package repository
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"faas-project/internal/api/apierror"
+	"faas-project/internal/i18n"
+	"faas-project/internal/logging"
+	"faas-project/internal/metrics"
 	"faas-project/internal/models"
+	"faas-project/internal/tracing"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +24,28 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+var (
+	ErrFunctionNotFound = errors.New("función no encontrada")
+	ErrFunctionExists   = errors.New("ya existe una función con ese nombre")
+)
+
+// FunctionRepository stores the function definitions. Implementations
+// report missing and duplicated functions with ErrFunctionNotFound and
+// ErrFunctionExists.
+type FunctionRepository interface {
+	CreateFunction(function models.Function) error
+	GetFunction(namespace, name string) (models.Function, error)
+	GetFunctionsByUser(ownerId string) ([]models.Function, error)
+	DeleteFunction(function models.Function) error
+	Update(function models.Function) error
+}
+
+// FunctionInvoker hands an invocation to the workers and writes the result
+// to w.
+type FunctionInvoker interface {
+	PublishFunction(ctx context.Context, function models.Function, caller, param string, w http.ResponseWriter)
+}
+
 type PendingFunction struct {
 	Function models.Function
 	Param    string
@@ -24,25 +55,25 @@ type ExecutionRequest struct {
 	Function    models.Function `json:"function"`
 	Param       string          `json:"param"`
 	ContainerId string          `json:"containerId"`
+	Caller      string          `json:"caller"`
 }
 type NatsFunctionRepository struct {
 	conn *nats.Conn
 	js   nats.JetStreamContext
 }
 
-var NatsConnection *nats.Conn
-var jsGlobal nats.JetStreamContext
-
 var natsURL = "nats://nats:4222"
 var REQUEST_TTL, _ = strconv.Atoi(os.Getenv("REQUEST_TTL"))
 
+// cleanDockerOutput strips the 8-byte stream header Docker prepends to each
+// log frame. Error messages published by the worker have no header.
 func cleanDockerOutput(output string) string {
-	if len(output) < 8 {
+	if len(output) < 8 || (output[0] != 1 && output[0] != 2) || output[1:4] != "\x00\x00\x00" {
 		return strings.TrimSpace(output)
 	}
 	return strings.TrimSpace(output[8:])
 }
-func (*NatsFunctionRepository) PublishFunction(function models.Function, param string, w http.ResponseWriter) {
+func (*NatsFunctionRepository) PublishFunction(ctx context.Context, function models.Function, caller, param string, w http.ResponseWriter) {
 
 	nc, err := nats.Connect(natsURL)
 	if err != nil {
@@ -52,16 +83,17 @@ func (*NatsFunctionRepository) PublishFunction(function models.Function, param s
 
 	containerId := fmt.Sprintf("faas-%s", uuid.New().String())
 
+	ctx, span := tracing.Start(ctx, "PublishFunction", tracing.FunctionAttributes(function.Name, containerId, function.OwnerId)...)
+	defer span.End()
+
 	data, err := json.Marshal(ExecutionRequest{
 		Function:    function,
 		Param:       param,
 		ContainerId: containerId,
+		Caller:      caller,
 	})
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "error",
-			"msg":    "Error al serializar la solicitud de ejecución" + err.Error(),
-		})
+		apierror.Write(w, http.StatusInternalServerError, "invocation_encode_failed", i18n.Message(i18n.FromContext(ctx), "invocation_encode_failed", err))
 		return
 	}
 	executeSubject := fmt.Sprintf("functions.%s", containerId)
@@ -72,6 +104,10 @@ func (*NatsFunctionRepository) PublishFunction(function models.Function, param s
 		Data:    data,
 		Reply:   replySubject,
 	}
+	tracing.InjectNATS(ctx, msg)
+	msg.Header.Set(logging.RequestIDHeader, logging.RequestID(ctx))
+	logging.FromContext(ctx).Info("publicando ejecución",
+		"function", function.Name, "user", function.OwnerId, "execution_id", containerId)
 
 	responseChan := make(chan string)
 
@@ -80,212 +116,199 @@ func (*NatsFunctionRepository) PublishFunction(function models.Function, param s
 		responseChan <- cleanOuput
 	})
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "error",
-			"msg":    "Error en subscripción: " + err.Error(),
-		})
+		metrics.Invocations.WithLabelValues(function.Name, "error").Inc()
+		apierror.Write(w, http.StatusInternalServerError, "invocation_publish_failed", i18n.Message(i18n.FromContext(ctx), "invocation_publish_failed", err))
 		return
 	}
 	defer sub.Unsubscribe()
 
+	start := time.Now()
+	metrics.InvocationsInFlight.Inc()
+	defer metrics.InvocationsInFlight.Dec()
+	defer func() {
+		metrics.InvocationDuration.WithLabelValues(function.Name).Observe(metrics.Since(start))
+	}()
+
 	nc.PublishMsg(msg)
 	select {
 	case response := <-responseChan:
+		metrics.Invocations.WithLabelValues(function.Name, "success").Inc()
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{
 			"status": "success",
 			"result": response,
 		})
 	case <-time.After(time.Duration(REQUEST_TTL) * time.Second):
-		w.WriteHeader(http.StatusGatewayTimeout)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "error",
-			"msg":    "Timeout esperando respuesta",
-		})
+		logging.FromContext(ctx).Warn("timeout esperando respuesta del worker",
+			"function", function.Name, "user", function.OwnerId, "execution_id", containerId)
+		metrics.Invocations.WithLabelValues(function.Name, "timeout").Inc()
+		tracing.RecordError(span, fmt.Errorf("timeout esperando respuesta"))
+		apierror.Write(w, http.StatusGatewayTimeout, apierror.Timeout, i18n.Message(i18n.FromContext(ctx), apierror.Timeout))
 	}
 }
 
-func GetFunctionRepository() *NatsFunctionRepository {
-	if NatsConnection == nil || jsGlobal == nil {
-		return initFunctionRepository()
-	} else {
-		return &NatsFunctionRepository{
-			conn: NatsConnection,
-			js:   jsGlobal,
-		}
-	}
+func NewNatsFunctionRepository(nc *nats.Conn, js nats.JetStreamContext) *NatsFunctionRepository {
+	return &NatsFunctionRepository{conn: nc, js: js}
 }
-func initFunctionRepository() *NatsFunctionRepository {
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = "nats://nats:4222"
-	}
 
-	nc, err := nats.Connect(natsURL)
-	if err != nil {
-		log.Printf("Error al conectar con NATS: %v", err)
-		return nil
-	}
-	NatsConnection = nc
-	js, err := nc.JetStream()
-	if err != nil {
-		log.Printf("Error al obtener el contexto de JetStream: %v", err)
-		nc.Close()
-		return nil
-	}
+// Functions live in the "functions" bucket, one key per function:
+// "fn.<namespace>.<name>", where the namespace is the owner. Listing the
+// functions of an owner is a watch on the "fn.<namespace>.*" prefix.
 
-	_, err = js.CreateKeyValue(&nats.KeyValueConfig{
-		Bucket: "user_functions",
-	})
-	if err != nil && err.Error() != "stream name already in use" {
-		log.Printf("Error al crear el bucket de funciones del usuario: %v", err)
-		nc.Close()
-		return nil
+// functionKey builds the key of a function.
+func functionKey(namespace, name string) string {
+	return "fn." + KeyToken(namespace) + "." + KeyToken(name)
+}
+
+func namespacePrefix(namespace string) string {
+	return "fn." + KeyToken(namespace) + ".*"
+}
+
+// KeyToken makes s usable as one token of a KV key or a subject. Values
+// with characters outside the KV alphabet, or with "." which separates
+// tokens, are stored base64url encoded behind a "=" marker, so different
+// values never share a token.
+func KeyToken(s string) string {
+	if s != "" && !strings.HasPrefix(s, "=") && strings.Trim(s, "-_=abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") == "" {
+		return s
 	}
-	NatsConnection = nc
-	jsGlobal = js
+	return "=" + base64.RawURLEncoding.EncodeToString([]byte(s))
+}
 
-	return &NatsFunctionRepository{
-		conn: nc,
-		js:   js,
+// isKeyToken reports whether token is something KeyToken returns, so data
+// already encoded is not encoded twice.
+func isKeyToken(token string) bool {
+	encoded, ok := strings.CutPrefix(token, "=")
+	if !ok {
+		return token != "" && KeyToken(token) == token
 	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	return err == nil && KeyToken(string(value)) == token
 }
+
+// CreateFunction stores a new function. It fails with ErrFunctionExists if
+// the owner already has one with that name.
 func (r *NatsFunctionRepository) CreateFunction(function models.Function) error {
-	kv, err := r.js.KeyValue("user_functions")
+	kv, err := r.js.KeyValue("functions")
 	if err != nil {
 		return err
 	}
-	userFunctionsEntry, err := kv.Get(function.OwnerId)
-	var functions []models.Function
-
+	data, err := encodeRecord(function)
 	if err != nil {
-		if err == nats.ErrKeyNotFound {
-			functions = []models.Function{}
-		} else {
-			return err
-		}
-	} else {
-		err = json.Unmarshal(userFunctionsEntry.Value(), &functions)
-		if err != nil {
-			return err
-		}
+		return err
+	}
+	revision, err := kv.Create(functionKey(function.OwnerId, function.Name), data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return ErrFunctionExists
 	}
-
-	functions = append(functions, function)
-	data, err := json.Marshal(functions)
 	if err != nil {
 		return err
 	}
-
-	_, err = kv.Put(function.OwnerId, data)
-	return err
+	if functionCache != nil {
+		functionCache.put(functionKey(function.OwnerId, function.Name), function, revision)
+	}
+	return nil
 }
 
-func (r *NatsFunctionRepository) GetFunctionByName(name string) (models.Function, error) {
-	kv, err := r.js.KeyValue("user_functions")
+// GetFunction returns the function name of the namespace.
+func (r *NatsFunctionRepository) GetFunction(namespace, name string) (models.Function, error) {
+	if functionCache != nil && functionCache.ready() {
+		if function, ok := functionCache.get(functionKey(namespace, name)); ok {
+			return function, nil
+		}
+		return models.Function{}, ErrFunctionNotFound
+	}
+	kv, err := r.js.KeyValue("functions")
 	if err != nil {
 		return models.Function{}, err
 	}
-
-	allUsers, err := kv.Keys()
+	entry, err := kv.Get(functionKey(namespace, name))
+	if err == nats.ErrKeyNotFound {
+		return models.Function{}, ErrFunctionNotFound
+	}
 	if err != nil {
 		return models.Function{}, err
 	}
-
-	for _, userId := range allUsers {
-		entry, err := kv.Get(userId)
-		if err != nil {
-			continue
-		}
-
-		var functions []models.Function
-		err = json.Unmarshal(entry.Value(), &functions)
-		if err != nil {
-			continue
-		}
-
-		for _, function := range functions {
-			if function.Name == name {
-				return function, nil
-			}
-		}
-	}
-
-	return models.Function{}, fmt.Errorf("function not found")
+	var function models.Function
+	err = decodeRecord(entry.Value(), &function)
+	return function, err
 }
 
+// DeleteFunction removes the function. The delete is conditional on the
+// revision read, so a concurrent update is not silently discarded.
 func (r *NatsFunctionRepository) DeleteFunction(function models.Function) error {
-	kv, err := r.js.KeyValue("user_functions")
-	if err != nil {
-		return err
-	}
-
-	entry, err := kv.Get(function.OwnerId)
-	if err != nil {
-		return err
-	}
-
-	var functions []models.Function
-	err = json.Unmarshal(entry.Value(), &functions)
+	kv, err := r.js.KeyValue("functions")
 	if err != nil {
 		return err
 	}
-
-	var updatedFunctions []models.Function
-	for _, f := range functions {
-		if f.Name != function.Name {
-			updatedFunctions = append(updatedFunctions, f)
+	key := functionKey(function.OwnerId, function.Name)
+	for i := 0; i < maxUpdateRetries; i++ {
+		entry, err := kv.Get(key)
+		if err == nats.ErrKeyNotFound {
+			return ErrFunctionNotFound
 		}
+		if err != nil {
+			return err
+		}
+		err = kv.Delete(key, nats.LastRevision(entry.Revision()))
+		if err == nil {
+			if functionCache != nil {
+				functionCache.delete(key, entry.Revision()+1)
+			}
+			return nil
+		}
+		if !isConflict(err) {
+			return err
+		}
+		conflictBackoff(i)
 	}
-
-	data, err := json.Marshal(updatedFunctions)
-	if err != nil {
-		return err
-	}
-
-	_, err = kv.Put(function.OwnerId, data)
-	return err
+	return ErrConflict
 }
 
+// GetFunctionsByUser lists the functions of a namespace.
 func (r *NatsFunctionRepository) GetFunctionsByUser(ownerId string) ([]models.Function, error) {
-	kv, err := r.js.KeyValue("user_functions")
+	if functionCache != nil && functionCache.ready() {
+		return functionCache.list(ownerId), nil
+	}
+	kv, err := r.js.KeyValue("functions")
 	if err != nil {
 		return nil, err
 	}
-
-	entry, err := kv.Get(ownerId)
+	watcher, err := kv.Watch(namespacePrefix(ownerId), nats.IgnoreDeletes())
 	if err != nil {
-		if err == nats.ErrKeyNotFound {
-			return []models.Function{}, nil
-		}
 		return nil, err
 	}
-	var functions []models.Function
-	err = json.Unmarshal(entry.Value(), &functions)
-	if err != nil {
-		var singleFunction models.Function
-		err = json.Unmarshal(entry.Value(), &singleFunction)
-		if err != nil {
-			return nil, err
+	defer watcher.Stop()
+
+	functions := []models.Function{}
+	// The watcher sends the current values and then a nil entry
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
 		}
-		functions = []models.Function{singleFunction}
+		var function models.Function
+		if err := decodeRecord(entry.Value(), &function); err != nil {
+			continue
+		}
+		functions = append(functions, function)
 	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
 	return functions, nil
 }
 
+// Update replaces a stored function, conditional on the revision read.
 func (r *NatsFunctionRepository) Update(function models.Function) error {
 	kv, err := r.js.KeyValue("functions")
 	if err != nil {
 		return err
 	}
-
-	data, err := json.Marshal(function)
-	if err != nil {
-		return err
-	}
-
-	_, err = kv.Put(function.Name, data)
-	return err
+	return updateRecord(kv, functionKey(function.OwnerId, function.Name), func(stored *models.Function) error {
+		if stored.Name == "" {
+			return ErrFunctionNotFound
+		}
+		*stored = function
+		return nil
+	})
 }
 
 func (r *NatsFunctionRepository) GetJS() nats.JetStreamContext {
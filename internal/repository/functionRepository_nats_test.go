@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"faas-project/internal/models"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runJetStream starts an embedded NATS server with JetStream for the test
+// and creates the given buckets.
+func runJetStream(t *testing.T, buckets ...string) nats.JetStreamContext {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatal("el servidor NATS no arrancó")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		if _, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return js
+}
+
+func TestCreateFunctionConcurrently(t *testing.T) {
+	js := runJetStream(t, "functions")
+	r := NewNatsFunctionRepository(nil, js)
+
+	// Every function is registered twice at the same time: exactly one of
+	// the two must win and none may be lost
+	const functions = 300
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := map[string]int{}
+	for i := 0; i < 2*functions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("fn-%d", i%functions)
+			err := r.CreateFunction(models.Function{Name: name, OwnerId: "alice", Image: "alpine"})
+			if err != nil && !errors.Is(err, ErrFunctionExists) {
+				t.Errorf("CreateFunction(%s) = %v", name, err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				created[name]++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < functions; i++ {
+		if name := fmt.Sprintf("fn-%d", i); created[name] != 1 {
+			t.Errorf("%s created %d times, want 1", name, created[name])
+		}
+	}
+	stored, err := r.GetFunctionsByUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != functions {
+		t.Errorf("GetFunctionsByUser returned %d functions, want %d", len(stored), functions)
+	}
+}
+
+func TestUpdateSharedKeyConcurrently(t *testing.T) {
+	js := runJetStream(t, "functions")
+	r := NewNatsFunctionRepository(nil, js)
+	if err := r.CreateFunction(models.Function{Name: "shared", OwnerId: "alice", Image: "alpine"}); err != nil {
+		t.Fatal(err)
+	}
+	kv, err := js.KeyValue("functions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, increments = 20, 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				err := updateRecord(kv, functionKey("alice", "shared"), func(function *models.Function) error {
+					function.MemoryMB++
+					return nil
+				})
+				if err != nil {
+					t.Errorf("updateRecord = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	function, err := r.GetFunction("alice", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if function.MemoryMB != writers*increments {
+		t.Errorf("MemoryMB = %d after %d increments", function.MemoryMB, writers*increments)
+	}
+	if function.Name != "shared" || function.Image != "alpine" {
+		t.Errorf("the increments overwrote the rest of the function: %+v", function)
+	}
+
+	// Update retries on conflicts instead of failing
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			update := function
+			update.Labels = map[string]string{"writer": fmt.Sprint(i)}
+			if err := r.Update(update); err != nil {
+				t.Errorf("Update = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if function, err = r.GetFunction("alice", "shared"); err != nil || function.Labels["writer"] == "" {
+		t.Errorf("GetFunction after the updates = %+v, %v", function, err)
+	}
+}
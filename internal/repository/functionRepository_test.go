@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+)
+
+// validToken is a single token of a KV key or subject: no "." and only
+// the characters NATS accepts in keys.
+var validToken = regexp.MustCompile(`^[-_=A-Za-z0-9]+$`)
+
+func TestKeyToken(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"alice", "alice"},
+		{"my-function_2", "my-function_2"},
+		{"a.b", "=YS5i"},
+		{"=YS5i", "=PVlTNWk"},
+		{"", "="},
+		{"alice@example.com", "=YWxpY2VAZXhhbXBsZS5jb20"},
+		{"ñ", "=w7E"},
+		{"*", "=Kg"},
+		{">", "=Pg"},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			if got := KeyToken(test.in); got != test.want {
+				t.Errorf("KeyToken(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeyTokenIsInjective(t *testing.T) {
+	values := []string{
+		"", "=", "==", "a", "A", "a.b", "a_b", "a-b", "a b", "a/b", "a.b.c",
+		"=YS5i", "=YQ", "YS5i", "alice", "alice.", ".alice", "alice@example.com",
+		"ghcr.io", "ghcr_io", "*", ">", "a*", "ñ", "n", "=w7E", "\x00", "a\x00b",
+	}
+	seen := map[string]string{}
+	for _, value := range values {
+		token := KeyToken(value)
+		if !validToken.MatchString(token) {
+			t.Errorf("KeyToken(%q) = %q is not a valid token", value, token)
+		}
+		if other, ok := seen[token]; ok {
+			t.Errorf("KeyToken(%q) = KeyToken(%q) = %q", value, other, token)
+		}
+		seen[token] = value
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const maxUpdateRetries = 20
+
+// ErrConflict is returned when an entry kept changing under an update for
+// maxUpdateRetries attempts.
+var ErrConflict = errors.New("demasiados conflictos al actualizar el valor")
+
+// isConflict reports whether a KV write failed because the entry revision
+// changed since it was read (or the key was created meanwhile).
+func isConflict(err error) bool {
+	if errors.Is(err, nats.ErrKeyExists) {
+		return true
+	}
+	var apiErr *nats.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence
+}
+
+// conflictBackoff spreads out the retries of writers racing on one key
+// (exponential backoff with full jitter, capped at 500ms).
+func conflictBackoff(attempt int) {
+	ceiling := 5 * time.Millisecond << attempt
+	if ceiling <= 0 || ceiling > 500*time.Millisecond {
+		ceiling = 500 * time.Millisecond
+	}
+	time.Sleep(time.Duration(rand.Int63n(int64(ceiling))))
+}
+
+// updateJSON applies update to the JSON value stored under key, using
+// kv.Create for new keys and kv.Update with the entry revision otherwise,
+// so concurrent writers from several API replicas do not lose updates. An
+// error from update aborts without writing.
+func updateJSON[T any](kv nats.KeyValue, key string, update func(*T) error) error {
+	return updateEncoded(kv, key, json.Unmarshal, json.Marshal, update)
+}
+
+// updateRecord is updateJSON for values stored in record envelopes.
+func updateRecord[T any](kv nats.KeyValue, key string, update func(*T) error) error {
+	return updateEncoded(kv, key, decodeRecord, encodeRecord, update)
+}
+
+func updateEncoded[T any](kv nats.KeyValue, key string, decode func([]byte, any) error, encode func(any) ([]byte, error), update func(*T) error) error {
+	for i := 0; i < maxUpdateRetries; i++ {
+		var value T
+		var revision uint64
+		entry, err := kv.Get(key)
+		if err == nil {
+			revision = entry.Revision()
+			if err := decode(entry.Value(), &value); err != nil {
+				return err
+			}
+		} else if err != nats.ErrKeyNotFound {
+			return err
+		}
+
+		if err := update(&value); err != nil {
+			return err
+		}
+		data, err := encode(value)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			_, err = kv.Create(key, data)
+		} else {
+			_, err = kv.Update(key, data, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		if !isConflict(err) {
+			return err
+		}
+		conflictBackoff(i)
+	}
+	return ErrConflict
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSLogRepository stores function output in the LOGS stream under
+// "logs.<namespace>.<function>.<execution>", one message per line.
+type NATSLogRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSLogRepository(js nats.JetStreamContext) *NATSLogRepository {
+	return &NATSLogRepository{js: js}
+}
+
+func logSubject(filter models.LogFilter) string {
+	if filter.ExecutionID != "" {
+		return fmt.Sprintf("logs.%s.%s.%s", KeyToken(filter.Namespace), KeyToken(filter.Function), KeyToken(filter.ExecutionID))
+	}
+	return fmt.Sprintf("logs.%s.%s.*", KeyToken(filter.Namespace), KeyToken(filter.Function))
+}
+
+// Append publishes the entry asynchronously. Flush waits for the pending
+// publishes to be acknowledged.
+func (r *NATSLogRepository) Append(entry models.LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("logs.%s.%s.%s", KeyToken(entry.Namespace), KeyToken(entry.Function), KeyToken(entry.ExecutionID))
+	_, err = r.js.PublishAsync(subject, data)
+	return err
+}
+
+func (r *NATSLogRepository) Flush(timeout time.Duration) error {
+	select {
+	case <-r.js.PublishAsyncComplete():
+		return nil
+	case <-time.After(timeout):
+		return nats.ErrTimeout
+	}
+}
+
+// Query returns the last filter.Limit entries of the function written
+// since filter.Since, oldest first.
+func (r *NATSLogRepository) Query(filter models.LogFilter) ([]models.LogEntry, error) {
+	opts := []nats.SubOpt{nats.OrderedConsumer()}
+	if filter.Since.IsZero() {
+		opts = append(opts, nats.DeliverAll())
+	} else {
+		opts = append(opts, nats.StartTime(filter.Since))
+	}
+	sub, err := r.js.SubscribeSync(logSubject(filter), opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	entries := []models.LogEntry{}
+	if isEmpty(sub) {
+		return entries, nil
+	}
+	for {
+		msg, err := sub.NextMsg(2 * time.Second)
+		if err == nats.ErrTimeout {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var entry models.LogEntry
+		// Restored entries are stored later than they were written
+		if err := json.Unmarshal(msg.Data, &entry); err == nil && !entry.Time.Before(filter.Since) {
+			entries = append(entries, entry)
+			if filter.Limit > 0 && len(entries) > filter.Limit {
+				entries = entries[1:]
+			}
+		}
+		if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// isEmpty reports whether the consumer of sub has nothing to deliver, so a
+// query over no messages returns at once instead of waiting for NextMsg to
+// time out.
+func isEmpty(sub *nats.Subscription) bool {
+	info, err := sub.ConsumerInfo()
+	return err == nil && info.NumPending == 0 && info.Delivered.Consumer == 0
+}
+
+// Follow streams the entries written since filter.Since, or only new ones
+// if it is zero, until ctx is cancelled. The channel is closed then.
+func (r *NATSLogRepository) Follow(ctx context.Context, filter models.LogFilter) (<-chan models.LogEntry, error) {
+	opts := []nats.SubOpt{nats.OrderedConsumer()}
+	if filter.Since.IsZero() {
+		opts = append(opts, nats.DeliverNew())
+	} else {
+		opts = append(opts, nats.StartTime(filter.Since))
+	}
+	msgs := make(chan *nats.Msg, 256)
+	sub, err := r.js.ChanSubscribe(logSubject(filter), msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(chan models.LogEntry)
+	go func() {
+		defer close(entries)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgs:
+				var entry models.LogEntry
+				if err := json.Unmarshal(msg.Data, &entry); err != nil {
+					continue
+				}
+				select {
+				case entries <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return entries, nil
+}
+
+// Purge removes the logs of a function, so a new function registered under
+// the same name does not inherit them.
+func (r *NATSLogRepository) Purge(namespace, function string) error {
+	return r.js.PurgeStream("LOGS", &nats.StreamPurgeRequest{
+		Subject: fmt.Sprintf("logs.%s.%s.>", KeyToken(namespace), KeyToken(function)),
+	})
+}
+
+func GetLogRepository() *NATSLogRepository {
+	return NewNATSLogRepository(message.GetJetStream())
+}
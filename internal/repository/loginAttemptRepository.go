@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"encoding/json"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSLoginAttemptRepository stores failed login counters per username
+// ("user.<name>") and per client IP ("ip.<addr>").
+type NATSLoginAttemptRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSLoginAttemptRepository(js nats.JetStreamContext) *NATSLoginAttemptRepository {
+	return &NATSLoginAttemptRepository{js: js}
+}
+
+// UserAttemptKey is the key of the failures of a username, which is the
+// one the client typed and may hold any character.
+func UserAttemptKey(username string) string {
+	return "user." + KeyToken(username)
+}
+
+func IPAttemptKey(ip string) string {
+	// ":" is not valid in KV keys (IPv6)
+	return "ip." + strings.ReplaceAll(ip, ":", "-")
+}
+
+func (r *NATSLoginAttemptRepository) Get(key string) (models.LoginAttempts, error) {
+	kv, err := r.js.KeyValue("login_attempts")
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+	entry, err := kv.Get(key)
+	if err == nats.ErrKeyNotFound {
+		return models.LoginAttempts{}, nil
+	}
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+	var attempts models.LoginAttempts
+	err = json.Unmarshal(entry.Value(), &attempts)
+	return attempts, err
+}
+
+// RecordFailure increments the counter for key and applies the lockout
+// returned by lockoutFor. Concurrent failures are serialized through the
+// entry revision so none of them is lost.
+func (r *NATSLoginAttemptRepository) RecordFailure(key string, lockoutFor func(failures int) time.Duration) (models.LoginAttempts, error) {
+	kv, err := r.js.KeyValue("login_attempts")
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+	var attempts models.LoginAttempts
+	err = updateJSON(kv, key, func(stored *models.LoginAttempts) error {
+		stored.Failures++
+		stored.LastFailure = time.Now()
+		if lockout := lockoutFor(stored.Failures); lockout > 0 {
+			stored.LockedUntil = stored.LastFailure.Add(lockout)
+		}
+		attempts = *stored
+		return nil
+	})
+	return attempts, err
+}
+
+func (r *NATSLoginAttemptRepository) Reset(key string) error {
+	kv, err := r.js.KeyValue("login_attempts")
+	if err != nil {
+		return err
+	}
+	err = kv.Delete(key)
+	if err == nats.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+func GetLoginAttemptRepository() *NATSLoginAttemptRepository {
+	return NewNATSLoginAttemptRepository(message.GetJetStream())
+}
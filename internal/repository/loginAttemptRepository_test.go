@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordFailureWithAnyUsername(t *testing.T) {
+	js := runJetStream(t, "login_attempts")
+	r := NewNATSLoginAttemptRepository(js)
+	lockout := func(failures int) time.Duration { return 0 }
+
+	for _, username := range []string{"alice", "alice smith", "a*", "a>", "a.b", "ñandú", ""} {
+		attempts, err := r.RecordFailure(UserAttemptKey(username), lockout)
+		if err != nil {
+			t.Errorf("RecordFailure(%q) = %v", username, err)
+			continue
+		}
+		if attempts.Failures != 1 {
+			t.Errorf("RecordFailure(%q) counted %d failures, want 1", username, attempts.Failures)
+		}
+	}
+}
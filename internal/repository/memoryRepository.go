@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"faas-project/internal/models"
+	"sort"
+	"sync"
+)
+
+// MemoryFunctionRepository keeps the functions in the process memory. It is
+// meant for tests and local runs: nothing survives a restart and replicas do
+// not share it.
+type MemoryFunctionRepository struct {
+	mu        sync.RWMutex
+	functions map[string]map[string]models.Function
+}
+
+func NewMemoryFunctionRepository() *MemoryFunctionRepository {
+	return &MemoryFunctionRepository{functions: map[string]map[string]models.Function{}}
+}
+
+func (r *MemoryFunctionRepository) CreateFunction(function models.Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	namespace := r.functions[function.OwnerId]
+	if namespace == nil {
+		namespace = map[string]models.Function{}
+		r.functions[function.OwnerId] = namespace
+	}
+	if _, ok := namespace[function.Name]; ok {
+		return ErrFunctionExists
+	}
+	namespace[function.Name] = function
+	return nil
+}
+
+func (r *MemoryFunctionRepository) GetFunction(namespace, name string) (models.Function, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	function, ok := r.functions[namespace][name]
+	if !ok {
+		return models.Function{}, ErrFunctionNotFound
+	}
+	return function, nil
+}
+
+func (r *MemoryFunctionRepository) GetFunctionsByUser(ownerId string) ([]models.Function, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	functions := []models.Function{}
+	for _, function := range r.functions[ownerId] {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+	return functions, nil
+}
+
+func (r *MemoryFunctionRepository) DeleteFunction(function models.Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.functions[function.OwnerId][function.Name]; !ok {
+		return ErrFunctionNotFound
+	}
+	delete(r.functions[function.OwnerId], function.Name)
+	return nil
+}
+
+func (r *MemoryFunctionRepository) Update(function models.Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.functions[function.OwnerId][function.Name]; !ok {
+		return ErrFunctionNotFound
+	}
+	r.functions[function.OwnerId][function.Name] = function
+	return nil
+}
+
+// MemoryUserRepository is the in-memory counterpart of NATSUserRepository.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: map[string]models.User{}}
+}
+
+func (r *MemoryUserRepository) CreateUser(user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.Username]; ok {
+		return ErrUserExists
+	}
+	r.users[user.Username] = user
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByUsername(username string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[username]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(username string, update func(*models.User) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if err := update(&user); err != nil {
+		return err
+	}
+	user.Username = username
+	r.users[username] = user
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"faas-project/internal/models"
+)
+
+func TestMemoryUserRepositoryUpdateUser(t *testing.T) {
+	users := NewMemoryUserRepository()
+	if err := users.UpdateUser("alice", func(*models.User) error { return nil }); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("UpdateUser on a missing user = %v, want ErrUserNotFound", err)
+	}
+	if err := users.CreateUser(models.User{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	abort := errors.New("abort")
+	if err := users.UpdateUser("alice", func(user *models.User) error {
+		user.Plan = "pro"
+		return abort
+	}); err != abort {
+		t.Fatalf("UpdateUser = %v, want the error of update", err)
+	}
+	if user, _ := users.GetByUsername("alice"); user.Plan != "" {
+		t.Errorf("an aborted update was stored: plan %q", user.Plan)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users.UpdateUser("alice", func(user *models.User) error {
+				user.Roles = append(user.Roles, "r")
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if user, _ := users.GetByUsername("alice"); len(user.Roles) != 50 || user.Username != "alice" {
+		t.Errorf("concurrent updates were lost: %d of 50", len(user.Roles))
+	}
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"faas-project/internal/models"
+
+	"github.com/nats-io/nats.go"
+)
+
+// The schema version of the stored data is kept under schemaVersionKey in
+// the "platform_meta" bucket. Data without it predates the migrations and
+// is schema 1.
+const (
+	schemaVersionKey = "schema_version"
+	migrationLockKey = "migrations"
+	// The "locks" bucket expires entries after 30s, so the lock of an API
+	// that died while migrating frees itself; the holder refreshes it.
+	lockRefreshInterval = 10 * time.Second
+	lockRetryInterval   = 2 * time.Second
+)
+
+// Migration upgrades the stored data to schema Version from the previous
+// one. Migrations must be safe to run again on data they already upgraded,
+// since an API can die between running one and recording the version.
+type Migration struct {
+	Version     int
+	Description string
+	Run         func(js nats.JetStreamContext, logger *slog.Logger) error
+}
+
+// migrations are run in order; the last one's Version is SchemaVersion.
+var migrations = []Migration{
+	{Version: 2, Description: "usuarios en sobres versionados", Run: migrateUsersToRecords},
+	{Version: 3, Description: "funciones de user_functions a una clave por función", Run: migrateLegacyFunctions},
+	{Version: 4, Description: "funciones en sobres versionados", Run: migrateFunctionsToRecords},
+	{Version: 5, Description: "credenciales de registros con claves codificadas", Run: migrateCredentialKeys},
+	{Version: 6, Description: "contadores de uso con el usuario codificado", Run: migrateUsageKeys},
+}
+
+// Migrate brings the stored data up to SchemaVersion. Only the API holding
+// the migrations lock runs them; the others wait until the data is current.
+// owner identifies the caller in the lock.
+func Migrate(ctx context.Context, js nats.JetStreamContext, owner string, logger *slog.Logger) error {
+	meta, err := js.KeyValue("platform_meta")
+	if err != nil {
+		return err
+	}
+	locks, err := js.KeyValue("locks")
+	if err != nil {
+		return err
+	}
+
+	for {
+		version, err := storedSchemaVersion(meta)
+		if err != nil {
+			return err
+		}
+		if version > SchemaVersion {
+			return fmt.Errorf("los datos tienen el esquema %d, más nuevo que el de esta versión (%d)", version, SchemaVersion)
+		}
+		if version == SchemaVersion {
+			return nil
+		}
+
+		revision, err := locks.Create(migrationLockKey, []byte(owner))
+		if err == nil {
+			return runMigrations(ctx, meta, locks, owner, revision, js, logger)
+		}
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return err
+		}
+		logger.Info("esperando a que otra instancia termine las migraciones", "schema_version", version)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func runMigrations(ctx context.Context, meta, locks nats.KeyValue, owner string, revision uint64, js nats.JetStreamContext, logger *slog.Logger) error {
+	// Keep the lock alive while migrating and release it when done
+	done := make(chan struct{})
+	refreshed := make(chan uint64, 1)
+	go func() {
+		current := revision
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				refreshed <- current
+				return
+			case <-ticker.C:
+				next, err := locks.Update(migrationLockKey, []byte(owner), current)
+				if err != nil {
+					logger.Error("error al renovar el bloqueo de migraciones", "error", err)
+					continue
+				}
+				current = next
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		if err := locks.Delete(migrationLockKey, nats.LastRevision(<-refreshed)); err != nil {
+			logger.Warn("error al liberar el bloqueo de migraciones", "error", err)
+		}
+	}()
+
+	// Another instance may have finished between the check and the lock
+	version, err := storedSchemaVersion(meta)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		logger.Info("aplicando migración", "schema_version", migration.Version, "description", migration.Description)
+		start := time.Now()
+		if err := migration.Run(js, logger); err != nil {
+			return fmt.Errorf("migración %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		if _, err := meta.Put(schemaVersionKey, []byte(strconv.Itoa(migration.Version))); err != nil {
+			return err
+		}
+		logger.Info("migración aplicada", "schema_version", migration.Version, "duration_ms", time.Since(start).Milliseconds())
+	}
+	return nil
+}
+
+// SetSchemaVersion records the schema version of the stored data, as after
+// restoring a backup taken with an older release.
+func SetSchemaVersion(js nats.JetStreamContext, version int) error {
+	meta, err := js.KeyValue("platform_meta")
+	if err != nil {
+		return err
+	}
+	_, err = meta.Put(schemaVersionKey, []byte(strconv.Itoa(version)))
+	return err
+}
+
+// ReadSchemaVersion returns the schema version of the stored data.
+func ReadSchemaVersion(js nats.JetStreamContext) (int, error) {
+	meta, err := js.KeyValue("platform_meta")
+	if err == nats.ErrBucketNotFound {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return storedSchemaVersion(meta)
+}
+
+func storedSchemaVersion(meta nats.KeyValue) (int, error) {
+	entry, err := meta.Get(schemaVersionKey)
+	if err == nats.ErrKeyNotFound {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(entry.Value()))
+	if err != nil {
+		return 0, fmt.Errorf("versión de esquema inválida %q", entry.Value())
+	}
+	return version, nil
+}
+
+// rewriteEntries passes every entry of bucket that is not a record
+// envelope yet to convert and stores the result as a record. Entries
+// written meanwhile by someone else are left alone: any current writer
+// already stores records.
+func rewriteEntries(js nats.JetStreamContext, bucket string, logger *slog.Logger, convert func(key string, value []byte) (any, error)) error {
+	kv, err := js.KeyValue(bucket)
+	if err != nil {
+		return err
+	}
+	watcher, err := kv.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+	var entries []nats.KeyValueEntry
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	watcher.Stop()
+
+	for _, entry := range entries {
+		if recordVersion(entry.Value()) > 0 {
+			continue
+		}
+		value, err := convert(entry.Key(), entry.Value())
+		if err != nil {
+			logger.Error("entrada con formato inválido, se deja como está", "bucket", bucket, "key", entry.Key(), "error", err)
+			continue
+		}
+		data, err := encodeRecord(value)
+		if err != nil {
+			return err
+		}
+		if _, err := kv.Update(entry.Key(), data, entry.Revision()); err != nil && !isConflict(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateUsersToRecords(js nats.JetStreamContext, logger *slog.Logger) error {
+	return rewriteEntries(js, "users", logger, func(key string, value []byte) (any, error) {
+		var user models.User
+		if err := json.Unmarshal(value, &user); err != nil {
+			// Users stored as the bare password hash
+			user = models.User{Password: string(value)}
+		}
+		user.Username = key
+		return user, nil
+	})
+}
+
+func migrateFunctionsToRecords(js nats.JetStreamContext, logger *slog.Logger) error {
+	return rewriteEntries(js, "functions", logger, func(key string, value []byte) (any, error) {
+		var function models.Function
+		err := json.Unmarshal(value, &function)
+		return function, err
+	})
+}
+
+// migrateCredentialKeys moves the registry credentials to the keys of
+// credentialKey. Before schema 5 the key was the raw namespace and
+// registry joined by ".", which did not tell "a"/"b.ghcr.io" apart from
+// "a.b"/"ghcr.io"; the namespace and registry stored in the value do.
+func migrateCredentialKeys(js nats.JetStreamContext, logger *slog.Logger) error {
+	kv, err := js.KeyValue("registry_credentials")
+	if err == nats.ErrBucketNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	watcher, err := kv.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+	var entries []nats.KeyValueEntry
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	watcher.Stop()
+
+	for _, entry := range entries {
+		var credential models.RegistryCredential
+		if err := json.Unmarshal(entry.Value(), &credential); err != nil {
+			logger.Error("entrada con formato inválido, se deja como está", "bucket", "registry_credentials", "key", entry.Key(), "error", err)
+			continue
+		}
+		key := credentialKey(credential.Namespace, credential.Registry)
+		if key == entry.Key() {
+			continue
+		}
+		if _, err := kv.Create(key, entry.Value()); err != nil && !errors.Is(err, nats.ErrKeyExists) {
+			return err
+		}
+		if err := kv.Delete(entry.Key(), nats.LastRevision(entry.Revision())); err != nil && !isConflict(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUsageKeys moves the usage counters to the keys of dayKey and
+// monthKey. Before schema 6 the user went into the key as is; since the
+// key always ends in "day.<date>" or "month.<month>", the user is
+// everything before that. Users that are already a KeyToken are left
+// alone, so running it again (or on a restored backup that mixes both)
+// does not encode them twice.
+func migrateUsageKeys(js nats.JetStreamContext, logger *slog.Logger) error {
+	kv, err := js.KeyValue("usage")
+	if err == nats.ErrBucketNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	watcher, err := kv.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+	var entries []nats.KeyValueEntry
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	watcher.Stop()
+
+	for _, entry := range entries {
+		tokens := strings.Split(entry.Key(), ".")
+		if len(tokens) < 3 || (tokens[len(tokens)-2] != "day" && tokens[len(tokens)-2] != "month") {
+			logger.Error("clave con formato inválido, se deja como está", "bucket", "usage", "key", entry.Key())
+			continue
+		}
+		if len(tokens) == 3 && isKeyToken(tokens[0]) {
+			continue
+		}
+		user := strings.Join(tokens[:len(tokens)-2], ".")
+		key := strings.Join([]string{KeyToken(user), tokens[len(tokens)-2], tokens[len(tokens)-1]}, ".")
+		if _, err := kv.Create(key, entry.Value()); err != nil && !errors.Is(err, nats.ErrKeyExists) {
+			return err
+		}
+		if err := kv.Delete(entry.Key(), nats.LastRevision(entry.Revision())); err != nil && !isConflict(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyFunctions copies the functions kept as one array per owner
+// in "user_functions" to their own keys. Existing functions are not
+// overwritten and the legacy bucket is kept; migrate-functions -delete-old
+// removes it.
+func migrateLegacyFunctions(js nats.JetStreamContext, logger *slog.Logger) error {
+	legacy, err := js.KeyValue("user_functions")
+	if err == nats.ErrBucketNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	owners, err := legacy.Keys()
+	if err == nats.ErrNoKeysFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	functionRepository := &NatsFunctionRepository{js: js}
+	for _, owner := range owners {
+		entry, err := legacy.Get(owner)
+		if err == nats.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		functions, err := DecodeLegacyFunctions(owner, entry.Value())
+		if err != nil {
+			logger.Error("entrada con formato inválido en user_functions", "user", owner, "error", err)
+			continue
+		}
+		for _, function := range functions {
+			if err := functionRepository.CreateFunction(function); err != nil && err != ErrFunctionExists {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeLegacyFunctions reads a "user_functions" entry: an array of the
+// owner's functions or, in some old entries, a single function.
+func DecodeLegacyFunctions(owner string, value []byte) ([]models.Function, error) {
+	var functions []models.Function
+	if err := json.Unmarshal(value, &functions); err != nil {
+		var function models.Function
+		if err := json.Unmarshal(value, &function); err != nil {
+			return nil, err
+		}
+		functions = []models.Function{function}
+	}
+	for i := range functions {
+		if functions[i].OwnerId == "" {
+			functions[i].OwnerId = owner
+		}
+	}
+	return functions, nil
+}
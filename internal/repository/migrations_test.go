@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestMigrateUsageKeysTwice(t *testing.T) {
+	js := runJetStream(t, "usage")
+	kv, err := js.KeyValue("usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string]string{
+		"alice.day.2025-01-01": `{"invocations":1}`,
+		"a.b.day.2025-01-01":   `{"invocations":2}`,
+		"=YS5i.month.2025-01":  `{"invocations":3}`,
+		"=alice.month.2025-01": `{"invocations":4}`,
+		"a/b.month.2025-01":    `{"invocations":5}`,
+		"not-a-counter":        `{"invocations":6}`,
+	}
+	for key, value := range stored {
+		if _, err := kv.Put(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for run := 1; run <= 2; run++ {
+		if err := migrateUsageKeys(js, logger); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+
+	want := map[string]string{
+		"alice.day.2025-01-01":                `{"invocations":1}`,
+		KeyToken("a.b") + ".day.2025-01-01":   `{"invocations":2}`,
+		"=YS5i.month.2025-01":                 `{"invocations":3}`,
+		KeyToken("=alice") + ".month.2025-01": `{"invocations":4}`,
+		KeyToken("a/b") + ".month.2025-01":    `{"invocations":5}`,
+		"not-a-counter":                       `{"invocations":6}`,
+	}
+	keys, err := kv.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if len(keys) != len(want) {
+		t.Errorf("keys after migrating twice = %v", keys)
+	}
+	for key, value := range want {
+		entry, err := kv.Get(key)
+		if err == nats.ErrKeyNotFound {
+			t.Errorf("%s is missing, keys = %v", key, keys)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(entry.Value()) != value {
+			t.Errorf("%s = %s, want %s", key, entry.Value(), value)
+		}
+	}
+}
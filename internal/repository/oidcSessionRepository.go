@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"faas-project/internal/message"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSOIDCSessionRepository keeps the PKCE code verifier of pending OIDC
+// logins, keyed by the state parameter. Entries expire with the bucket TTL.
+type NATSOIDCSessionRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSOIDCSessionRepository(js nats.JetStreamContext) *NATSOIDCSessionRepository {
+	return &NATSOIDCSessionRepository{js: js}
+}
+
+func (r *NATSOIDCSessionRepository) Save(state string, codeVerifier string) error {
+	kv, err := r.js.KeyValue("oidc_sessions")
+	if err != nil {
+		return err
+	}
+	_, err = kv.Create(state, []byte(codeVerifier))
+	return err
+}
+
+// Take returns the code verifier for state and removes it, so each state can
+// only be redeemed once.
+func (r *NATSOIDCSessionRepository) Take(state string) (string, error) {
+	kv, err := r.js.KeyValue("oidc_sessions")
+	if err != nil {
+		return "", err
+	}
+	entry, err := kv.Get(state)
+	if err != nil {
+		return "", err
+	}
+	if err := kv.Delete(state, nats.LastRevision(entry.Revision())); err != nil {
+		return "", err
+	}
+	return string(entry.Value()), nil
+}
+
+func GetOIDCSessionRepository() *NATSOIDCSessionRepository {
+	return NewNATSOIDCSessionRepository(message.GetJetStream())
+}
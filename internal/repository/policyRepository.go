@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"encoding/json"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+
+	"github.com/nats-io/nats.go"
+)
+
+const imagePolicyKey = "image_policy"
+
+// NATSPolicyRepository stores the platform image policy in the
+// "platform_config" bucket and registry pull credentials per namespace in
+// "registry_credentials".
+type NATSPolicyRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSPolicyRepository(js nats.JetStreamContext) *NATSPolicyRepository {
+	return &NATSPolicyRepository{js: js}
+}
+
+// GetImagePolicy returns the configured policy, or an empty (allow all)
+// policy if none has been set.
+func (r *NATSPolicyRepository) GetImagePolicy() (models.ImagePolicy, error) {
+	kv, err := r.js.KeyValue("platform_config")
+	if err == nats.ErrBucketNotFound {
+		return models.ImagePolicy{}, nil
+	}
+	if err != nil {
+		return models.ImagePolicy{}, err
+	}
+	entry, err := kv.Get(imagePolicyKey)
+	if err == nats.ErrKeyNotFound {
+		return models.ImagePolicy{}, nil
+	}
+	if err != nil {
+		return models.ImagePolicy{}, err
+	}
+	var policy models.ImagePolicy
+	err = json.Unmarshal(entry.Value(), &policy)
+	return policy, err
+}
+
+func (r *NATSPolicyRepository) SaveImagePolicy(policy models.ImagePolicy) error {
+	kv, err := r.js.KeyValue("platform_config")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(imagePolicyKey, data)
+	return err
+}
+
+// credentialKey is "<namespace>.<registry>", each part encoded so that no
+// two namespaces can address the same key.
+func credentialKey(namespace, registry string) string {
+	return KeyToken(namespace) + "." + KeyToken(registry)
+}
+
+func (r *NATSPolicyRepository) SaveRegistryCredential(credential models.RegistryCredential) error {
+	kv, err := r.js.KeyValue("registry_credentials")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(credentialKey(credential.Namespace, credential.Registry), data)
+	return err
+}
+
+func (r *NATSPolicyRepository) DeleteRegistryCredential(namespace, registry string) error {
+	kv, err := r.js.KeyValue("registry_credentials")
+	if err != nil {
+		return err
+	}
+	return kv.Delete(credentialKey(namespace, registry))
+}
+
+// GetRegistryCredential returns nats.ErrKeyNotFound if the namespace has no
+// credential for the registry.
+func (r *NATSPolicyRepository) GetRegistryCredential(namespace, registry string) (models.RegistryCredential, error) {
+	kv, err := r.js.KeyValue("registry_credentials")
+	if err == nats.ErrBucketNotFound {
+		return models.RegistryCredential{}, nats.ErrKeyNotFound
+	}
+	if err != nil {
+		return models.RegistryCredential{}, err
+	}
+	entry, err := kv.Get(credentialKey(namespace, registry))
+	if err != nil {
+		return models.RegistryCredential{}, err
+	}
+	var credential models.RegistryCredential
+	err = json.Unmarshal(entry.Value(), &credential)
+	return credential, err
+}
+
+func GetPolicyRepository() *NATSPolicyRepository {
+	return NewNATSPolicyRepository(message.GetJetStream())
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "testing"
+
+func TestCredentialKeyTellsNamespacesApart(t *testing.T) {
+	tests := []struct{ a, b [2]string }{
+		{[2]string{"a", "b.ghcr.io"}, [2]string{"a.b", "ghcr.io"}},
+		{[2]string{"a", "ghcr.io"}, [2]string{"a", "ghcr_io"}},
+		{[2]string{"=YQ", "ghcr.io"}, [2]string{"a", "ghcr.io"}},
+	}
+	for _, test := range tests {
+		if credentialKey(test.a[0], test.a[1]) == credentialKey(test.b[0], test.b[1]) {
+			t.Errorf("credentialKey(%q, %q) == credentialKey(%q, %q)", test.a[0], test.a[1], test.b[0], test.b[1])
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"faas-project/internal/models"
+
+	_ "github.com/lib/pq"
+)
+
+// Users and functions are kept as JSON documents, the same encoding used
+// in the KV buckets, so new fields need no schema change.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	username TEXT PRIMARY KEY,
+	data     JSONB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS functions (
+	namespace TEXT NOT NULL,
+	name      TEXT NOT NULL,
+	data      JSONB NOT NULL,
+	PRIMARY KEY (namespace, name)
+);`
+
+// OpenPostgres connects to the database at url and creates the tables if
+// they do not exist yet.
+func OpenPostgres(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+type PostgresFunctionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresFunctionRepository(db *sql.DB) *PostgresFunctionRepository {
+	return &PostgresFunctionRepository{db: db}
+}
+
+func (r *PostgresFunctionRepository) CreateFunction(function models.Function) error {
+	data, err := json.Marshal(function)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`INSERT INTO functions (namespace, name, data) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, function.OwnerId, function.Name, data)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrFunctionExists
+	}
+	return err
+}
+
+func (r *PostgresFunctionRepository) GetFunction(namespace, name string) (models.Function, error) {
+	var data []byte
+	err := r.db.QueryRow(`SELECT data FROM functions WHERE namespace = $1 AND name = $2`, namespace, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Function{}, ErrFunctionNotFound
+	}
+	if err != nil {
+		return models.Function{}, err
+	}
+	var function models.Function
+	err = json.Unmarshal(data, &function)
+	return function, err
+}
+
+func (r *PostgresFunctionRepository) GetFunctionsByUser(ownerId string) ([]models.Function, error) {
+	rows, err := r.db.Query(`SELECT data FROM functions WHERE namespace = $1 ORDER BY name`, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	functions := []models.Function{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var function models.Function
+		if err := json.Unmarshal(data, &function); err != nil {
+			continue
+		}
+		functions = append(functions, function)
+	}
+	return functions, rows.Err()
+}
+
+func (r *PostgresFunctionRepository) DeleteFunction(function models.Function) error {
+	result, err := r.db.Exec(`DELETE FROM functions WHERE namespace = $1 AND name = $2`, function.OwnerId, function.Name)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrFunctionNotFound
+	}
+	return err
+}
+
+func (r *PostgresFunctionRepository) Update(function models.Function) error {
+	data, err := json.Marshal(function)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`UPDATE functions SET data = $3 WHERE namespace = $1 AND name = $2`,
+		function.OwnerId, function.Name, data)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrFunctionNotFound
+	}
+	return err
+}
+
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) CreateUser(user models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`INSERT INTO users (username, data) VALUES ($1, $2) ON CONFLICT DO NOTHING`, user.Username, data)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (r *PostgresUserRepository) GetByUsername(username string) (models.User, error) {
+	var data []byte
+	err := r.db.QueryRow(`SELECT data FROM users WHERE username = $1`, username).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	var user models.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return models.User{}, err
+	}
+	user.Username = username
+	return user, nil
+}
+
+// UpdateUser implements UserRepository, locking the row for the duration
+// of update.
+func (r *PostgresUserRepository) UpdateUser(username string, update func(*models.User) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRow(`SELECT data FROM users WHERE username = $1 FOR UPDATE`, username).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var user models.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return err
+	}
+	user.Username = username
+	if err := update(&user); err != nil {
+		return err
+	}
+	user.Username = username
+	if data, err = json.Marshal(user); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET data = $2 WHERE username = $1`, username, data); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
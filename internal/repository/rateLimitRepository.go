@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"math"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSRateLimitRepository keeps token buckets in the "rate_limits" bucket so
+// every API replica enforces the same limits.
+type NATSRateLimitRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSRateLimitRepository(js nats.JetStreamContext) *NATSRateLimitRepository {
+	return &NATSRateLimitRepository{js: js}
+}
+
+// Take refills the bucket for key and consumes one token if available,
+// returning whether the request is allowed and the bucket state after it.
+func (r *NATSRateLimitRepository) Take(key string, limit models.RateLimit) (bool, models.TokenBucket, error) {
+	kv, err := r.js.KeyValue("rate_limits")
+	if err != nil {
+		return false, models.TokenBucket{}, err
+	}
+	var allowed bool
+	var bucket models.TokenBucket
+	err = updateJSON(kv, key, func(b *models.TokenBucket) error {
+		now := time.Now()
+		if b.Updated.IsZero() {
+			b.Tokens = float64(limit.Burst)
+		} else {
+			b.Tokens = math.Min(float64(limit.Burst), b.Tokens+now.Sub(b.Updated).Seconds()*limit.Rate)
+		}
+		b.Updated = now
+		allowed = b.Tokens >= 1
+		if allowed {
+			b.Tokens--
+		}
+		bucket = *b
+		return nil
+	})
+	return allowed, bucket, err
+}
+
+func GetRateLimitRepository() *NATSRateLimitRepository {
+	return NewNATSRateLimitRepository(message.GetJetStream())
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"encoding/json"
+)
+
+// SchemaVersion is the version of the format the platform stores its
+// records with. Every change to a stored format bumps it and adds the
+// migration that upgrades older data (see migrations.go).
+const SchemaVersion = 6
+
+// record is the envelope users and functions are stored in since schema 2.
+// V is the schema version the record was written with.
+type record struct {
+	V    int             `json:"v"`
+	Data json.RawMessage `json:"data"`
+}
+
+// encodeRecord wraps value in a record of the current schema version.
+func encodeRecord(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(record{V: SchemaVersion, Data: data})
+}
+
+// decodeRecord reads a value stored by encodeRecord or, for data not yet
+// migrated or written by an older release, as plain JSON.
+func decodeRecord(data []byte, value any) error {
+	if recordVersion(data) > 0 {
+		var stored record
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		return json.Unmarshal(stored.Data, value)
+	}
+	return json.Unmarshal(data, value)
+}
+
+// recordVersion returns the schema version of a stored record, or 0 if the
+// data is not a record envelope.
+func recordVersion(data []byte) int {
+	var probe struct {
+		V    *int            `json:"v"`
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(data, &probe) != nil || probe.V == nil || len(probe.Data) == 0 {
+		return 0
+	}
+	return *probe.V
+}
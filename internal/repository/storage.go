@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Storage is the backend holding users and functions. The rest of the
+// platform state (logs, executions, usage, ...) stays in JetStream.
+type Storage struct {
+	Backend   string
+	Functions FunctionRepository
+	Users     UserRepository
+	ping      func(ctx context.Context) error
+	close     func() error
+}
+
+// OpenStorage opens the backend selected by STORAGE_BACKEND: "nats" (the
+// default), "postgres", which connects to DATABASE_URL, or "memory".
+func OpenStorage(nc *nats.Conn, js nats.JetStreamContext) (*Storage, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "nats"
+	}
+	switch backend {
+	case "nats":
+		if err := StartFunctionCache(js); err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Backend:   backend,
+			Functions: NewNatsFunctionRepository(nc, js),
+			Users:     NewNATSUserRepository(js),
+			ping: func(ctx context.Context) error {
+				for _, bucket := range []string{"users", "functions"} {
+					kv, err := js.KeyValue(bucket)
+					if err != nil {
+						return fmt.Errorf("bucket %s: %w", bucket, err)
+					}
+					if _, err := kv.Status(); err != nil {
+						return fmt.Errorf("bucket %s: %w", bucket, err)
+					}
+				}
+				return nil
+			},
+			close: func() error { return nil },
+		}, nil
+	case "postgres":
+		url := os.Getenv("DATABASE_URL")
+		if url == "" {
+			return nil, fmt.Errorf("DATABASE_URL es obligatorio con STORAGE_BACKEND=postgres")
+		}
+		db, err := OpenPostgres(url)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Backend:   backend,
+			Functions: NewPostgresFunctionRepository(db),
+			Users:     NewPostgresUserRepository(db),
+			ping:      db.PingContext,
+			close:     db.Close,
+		}, nil
+	case "memory":
+		return &Storage{
+			Backend:   backend,
+			Functions: NewMemoryFunctionRepository(),
+			Users:     NewMemoryUserRepository(),
+			ping:      func(ctx context.Context) error { return nil },
+			close:     func() error { return nil },
+		}, nil
+	}
+	return nil, fmt.Errorf("STORAGE_BACKEND desconocido: %q", backend)
+}
+
+// Ping reports whether the backend can be reached.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.ping(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.close()
+}
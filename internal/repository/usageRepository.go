@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"encoding/json"
+	"faas-project/internal/message"
+	"faas-project/internal/models"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSUsageRepository keeps per-user usage counters in the "usage" bucket,
+// one key per day ("<user>.day.<YYYY-MM-DD>") and per month
+// ("<user>.month.<YYYY-MM>"), with the user encoded by KeyToken.
+// "usage_executions" keeps, for as long as the USAGE stream keeps the
+// records, the counters each execution has been added to.
+type NATSUsageRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSUsageRepository(js nats.JetStreamContext) *NATSUsageRepository {
+	return &NATSUsageRepository{js: js}
+}
+
+func dayKey(user string, t time.Time) string {
+	return fmt.Sprintf("%s.day.%s", KeyToken(user), t.UTC().Format("2006-01-02"))
+}
+
+func monthKey(user string, t time.Time) string {
+	return fmt.Sprintf("%s.month.%s", KeyToken(user), t.UTC().Format("2006-01"))
+}
+
+// Add accumulates a usage record into the day and month counters of its
+// user. The counters already updated for an execution are noted in the
+// "usage_executions" bucket under its ID, so a redelivered record only adds
+// what a previous attempt did not. The note is written after each counter,
+// so an attempt interrupted between the two writes counts that record
+// twice in one counter.
+func (r *NATSUsageRepository) Add(record models.UsageRecord) error {
+	kv, err := r.js.KeyValue("usage")
+	if err != nil {
+		return err
+	}
+	applied, err := r.js.KeyValue("usage_executions")
+	if err != nil {
+		return err
+	}
+	// Records without an ID cannot be told apart and are always added
+	var marker string
+	var done []string
+	if record.ExecutionID != "" {
+		marker = KeyToken(record.ExecutionID)
+		entry, err := applied.Get(marker)
+		if err == nil {
+			err = json.Unmarshal(entry.Value(), &done)
+		}
+		if err != nil && err != nats.ErrKeyNotFound {
+			return err
+		}
+	}
+	for _, key := range []string{dayKey(record.User, record.Start), monthKey(record.User, record.Start)} {
+		if slices.Contains(done, key) {
+			continue
+		}
+		err := updateJSON(kv, key, func(counters *models.UsageCounters) error {
+			counters.Invocations++
+			if record.Status != "success" {
+				counters.Errors++
+			}
+			counters.ContainerSeconds += record.Duration.Seconds()
+			counters.GBSeconds += record.GBSeconds()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if marker == "" {
+			continue
+		}
+		err = updateJSON(applied, marker, func(keys *[]string) error {
+			*keys = append(*keys, key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *NATSUsageRepository) GetDay(user string, t time.Time) (models.UsageCounters, error) {
+	return r.get(dayKey(user, t))
+}
+
+func (r *NATSUsageRepository) GetMonth(user string, t time.Time) (models.UsageCounters, error) {
+	return r.get(monthKey(user, t))
+}
+
+func (r *NATSUsageRepository) get(key string) (models.UsageCounters, error) {
+	kv, err := r.js.KeyValue("usage")
+	if err != nil {
+		return models.UsageCounters{}, err
+	}
+	entry, err := kv.Get(key)
+	if err == nats.ErrKeyNotFound {
+		return models.UsageCounters{}, nil
+	}
+	if err != nil {
+		return models.UsageCounters{}, err
+	}
+	var counters models.UsageCounters
+	err = json.Unmarshal(entry.Value(), &counters)
+	return counters, err
+}
+
+func GetUsageRepository() *NATSUsageRepository {
+	return NewNATSUsageRepository(message.GetJetStream())
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"faas-project/internal/models"
+)
+
+func TestUsageAddRedelivered(t *testing.T) {
+	js := runJetStream(t, "usage", "usage_executions")
+	r := NewNATSUsageRepository(js)
+	start := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	record := models.UsageRecord{ExecutionID: "exec-1", User: "alice", Start: start, Duration: time.Second, MemoryLimitMB: 1024, Status: "success"}
+
+	for i := 0; i < 2; i++ {
+		if err := r.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An attempt that stopped after the day counter
+	kv, err := js.KeyValue("usage_executions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal([]string{dayKey("alice", start)})
+	if _, err := kv.Put(KeyToken("exec-3"), data); err != nil {
+		t.Fatal(err)
+	}
+	partial := record
+	partial.ExecutionID = "exec-3"
+	if err := r.Add(partial); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(partial); err != nil {
+		t.Fatal(err)
+	}
+
+	day, err := r.GetDay("alice", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Invocations != 1 || day.GBSeconds != 1 {
+		t.Errorf("day = %+v, want 1 invocation and 1 GB-s", day)
+	}
+	month, err := r.GetMonth("alice", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if month.Invocations != 2 || month.GBSeconds != 2 {
+		t.Errorf("month = %+v, want 2 invocations and 2 GB-s", month)
+	}
+}
+
+func TestUsageAddWithoutExecutionID(t *testing.T) {
+	r := NewNATSUsageRepository(runJetStream(t, "usage", "usage_executions"))
+	start := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	record := models.UsageRecord{User: "alice", Start: start, Duration: time.Second, Status: "error"}
+	for i := 0; i < 2; i++ {
+		if err := r.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day, err := r.GetDay("alice", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Invocations != 2 || day.Errors != 2 {
+		t.Errorf("day = %+v, want 2 invocations and 2 errors", day)
+	}
+}
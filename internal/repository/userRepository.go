@@ -1,16 +1,28 @@
 package repository
 
 import (
-	"faas-project/internal/message"
+	"errors"
 	"faas-project/internal/models"
 
 	"github.com/nats-io/nats.go"
 )
 
+var (
+	ErrUserNotFound = errors.New("usuario no encontrado")
+	ErrUserExists   = errors.New("el usuario ya existe")
+)
+
+// UserRepository stores the platform users. GetByUsername and UpdateUser
+// fail with ErrUserNotFound and CreateUser with ErrUserExists, whatever the
+// backend. UpdateUser applies update to the stored user atomically, so
+// concurrent changes to one user (a login failure and a password reset,
+// say) are not lost; an error from update aborts without writing.
 type UserRepository interface {
 	CreateUser(user models.User) error
 	GetByUsername(username string) (models.User, error)
+	UpdateUser(username string, update func(*models.User) error) error
 }
+
 type NATSUserRepository struct {
 	js nats.JetStreamContext
 }
@@ -19,17 +31,22 @@ func NewNATSUserRepository(js nats.JetStreamContext) *NATSUserRepository {
 	return &NATSUserRepository{js: js}
 }
 
-// GetByUsername implements UserRepository.
+// CreateUser implements UserRepository. It fails with ErrUserExists if the
+// username was taken meanwhile.
 func (r *NATSUserRepository) CreateUser(user models.User) error {
 	kv, err := r.js.KeyValue("users")
 	if err != nil {
 		return err
 	}
-	_, err = kv.Put(user.Username, []byte(user.Password))
+	data, err := encodeRecord(user)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = kv.Create(user.Username, data)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return ErrUserExists
+	}
+	return err
 }
 
 func (r *NATSUserRepository) GetByUsername(username string) (models.User, error) {
@@ -37,19 +54,58 @@ func (r *NATSUserRepository) GetByUsername(username string) (models.User, error)
 	if err != nil {
 		return models.User{}, err
 	}
+	user, _, err := getUser(kv, username)
+	return user, err
+}
+
+// getUser reads a user together with the revision of its entry.
+func getUser(kv nats.KeyValue, username string) (models.User, uint64, error) {
 	entry, err := kv.Get(username)
+	if err == nats.ErrKeyNotFound {
+		return models.User{}, 0, ErrUserNotFound
+	}
 	if err != nil {
-		return models.User{}, err
+		return models.User{}, 0, err
 	}
 	var user models.User
+	err = decodeRecord(entry.Value(), &user)
+	if err != nil {
+		// Usuarios antiguos guardados solo con el hash de la contraseña
+		user = models.User{Password: string(entry.Value())}
+	}
 	user.Username = username
-	user.Password = string(entry.Value())
-
-	return user, nil
 
+	return user, entry.Revision(), nil
 }
 
-func GetUserRepository() *NATSUserRepository {
-	js := message.GetJetStream()
-	return NewNATSUserRepository(js)
+// UpdateUser implements UserRepository with kv.Update on the revision
+// read, retrying when another replica wrote the user meanwhile.
+func (r *NATSUserRepository) UpdateUser(username string, update func(*models.User) error) error {
+	kv, err := r.js.KeyValue("users")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < maxUpdateRetries; i++ {
+		user, revision, err := getUser(kv, username)
+		if err != nil {
+			return err
+		}
+		if err := update(&user); err != nil {
+			return err
+		}
+		user.Username = username
+		data, err := encodeRecord(user)
+		if err != nil {
+			return err
+		}
+		_, err = kv.Update(username, data, revision)
+		if err == nil {
+			return nil
+		}
+		if !isConflict(err) {
+			return err
+		}
+		conflictBackoff(i)
+	}
+	return ErrConflict
 }
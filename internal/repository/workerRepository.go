@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"encoding/json"
+	"faas-project/internal/message"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSWorkerRepository keeps the registry of live workers in the "workers"
+// bucket. Entries expire with the bucket TTL unless the worker refreshes
+// them with Heartbeat.
+type NATSWorkerRepository struct {
+	js nats.JetStreamContext
+}
+
+func NewNATSWorkerRepository(js nats.JetStreamContext) *NATSWorkerRepository {
+	return &NATSWorkerRepository{js: js}
+}
+
+func (r *NATSWorkerRepository) Heartbeat(id string) error {
+	kv, err := r.js.KeyValue("workers")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]time.Time{"lastSeen": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(id, data)
+	return err
+}
+
+// Alive reports whether the worker has sent a heartbeat within the TTL.
+func (r *NATSWorkerRepository) Alive(id string) (bool, error) {
+	kv, err := r.js.KeyValue("workers")
+	if err != nil {
+		return false, err
+	}
+	_, err = kv.Get(id)
+	if err == nats.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *NATSWorkerRepository) Remove(id string) error {
+	kv, err := r.js.KeyValue("workers")
+	if err != nil {
+		return err
+	}
+	return kv.Delete(id)
+}
+
+func GetWorkerRepository() *NATSWorkerRepository {
+	return NewNATSWorkerRepository(message.GetJetStream())
+}
@@ -0,0 +1,302 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// Network modes a function can run with.
+const (
+	// NetworkNone runs the container without any network interface.
+	NetworkNone = "none"
+	// NetworkEgress attaches the container to an isolated bridge with
+	// internet access but no route to NATS, the API or other functions.
+	NetworkEgress = "egress"
+	// NetworkInternal attaches the container to the platform network.
+	NetworkInternal = "internal"
+)
+
+// Profile is the resolved sandbox applied to one container.
+type Profile struct {
+	ReadOnlyRootFS bool
+	TmpfsSize      string
+	CapAdd         []string
+	User           string
+	SeccompProfile string
+	Runtime        string
+	NetworkMode    string
+}
+
+// Policy holds the platform defaults and what functions are allowed to
+// override. Both the API server (on registration) and the worker (before
+// creating the container) evaluate it.
+type Policy struct {
+	Default             Profile
+	AllowedNetworkModes []string
+	AllowedRuntimes     []string
+	AllowedCapabilities []string
+	AllowRoot           bool
+	AllowWritableRootFS bool
+	SeccompDir          string
+	EgressNetwork       string
+	InternalNetwork     string
+	DefaultMemoryMB     int64
+	MaxMemoryMB         int64
+	MaxTmpfsMB          int64
+}
+
+func LoadPolicy() Policy {
+	policy := Policy{
+		Default: Profile{
+			ReadOnlyRootFS: os.Getenv("SANDBOX_READONLY_ROOTFS") != "false",
+			TmpfsSize:      envOr("SANDBOX_TMPFS_SIZE", "64m"),
+			User:           envOr("SANDBOX_USER", "65534:65534"),
+			SeccompProfile: os.Getenv("SANDBOX_SECCOMP_PROFILE"),
+			Runtime:        os.Getenv("SANDBOX_RUNTIME"),
+			NetworkMode:    envOr("SANDBOX_NETWORK_MODE", NetworkEgress),
+		},
+		AllowedNetworkModes: envList("SANDBOX_ALLOWED_NETWORK_MODES", []string{NetworkNone, NetworkEgress}),
+		AllowedRuntimes:     envList("SANDBOX_ALLOWED_RUNTIMES", nil),
+		AllowedCapabilities: envList("SANDBOX_ALLOWED_CAPABILITIES", nil),
+		AllowRoot:           os.Getenv("SANDBOX_ALLOW_ROOT") == "true",
+		AllowWritableRootFS: os.Getenv("SANDBOX_ALLOW_WRITABLE_ROOTFS") == "true",
+		SeccompDir:          envOr("SANDBOX_SECCOMP_DIR", "/etc/faas/seccomp"),
+		EgressNetwork:       envOr("SANDBOX_EGRESS_NETWORK", "faas-egress"),
+		InternalNetwork:     envOr("SANDBOX_INTERNAL_NETWORK", "faas-project_faas-network"),
+		DefaultMemoryMB:     envInt64("SANDBOX_MEMORY_MB", 128),
+		MaxMemoryMB:         envInt64("SANDBOX_MAX_MEMORY_MB", 1024),
+		MaxTmpfsMB:          envInt64("SANDBOX_MAX_TMPFS_MB", 256),
+	}
+	return policy
+}
+
+// Resolve merges a function's overrides into the platform default and
+// rejects anything the policy does not allow.
+func (p Policy) Resolve(override *models.SecurityProfile) (Profile, error) {
+	profile := p.Default
+	profile.CapAdd = append([]string(nil), p.Default.CapAdd...)
+	if override == nil {
+		return profile, nil
+	}
+
+	if override.ReadOnlyRootFS != nil {
+		if !*override.ReadOnlyRootFS && !p.AllowWritableRootFS {
+			return Profile{}, i18n.New("sandbox_writable_rootfs")
+		}
+		profile.ReadOnlyRootFS = *override.ReadOnlyRootFS
+	}
+	if override.TmpfsSize != "" {
+		if _, err := p.tmpfsBytes(override.TmpfsSize); err != nil {
+			return Profile{}, err
+		}
+		profile.TmpfsSize = override.TmpfsSize
+	}
+	for _, capability := range override.AddCapabilities {
+		capability = strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if !contains(p.AllowedCapabilities, capability) {
+			return Profile{}, i18n.New("sandbox_capability_denied", capability)
+		}
+		profile.CapAdd = append(profile.CapAdd, capability)
+	}
+	if override.User != "" {
+		if isRoot(override.User) && !p.AllowRoot {
+			return Profile{}, i18n.New("sandbox_root_denied")
+		}
+		profile.User = override.User
+	}
+	if override.SeccompProfile != "" {
+		if override.SeccompProfile == "unconfined" || strings.ContainsAny(override.SeccompProfile, `/\`) {
+			return Profile{}, i18n.New("sandbox_seccomp_denied", override.SeccompProfile)
+		}
+		profile.SeccompProfile = override.SeccompProfile
+	}
+	if override.Runtime != "" {
+		if !contains(p.AllowedRuntimes, override.Runtime) {
+			return Profile{}, i18n.New("sandbox_runtime_denied", override.Runtime)
+		}
+		profile.Runtime = override.Runtime
+	}
+	if override.NetworkMode != "" {
+		switch override.NetworkMode {
+		case NetworkNone, NetworkEgress, NetworkInternal:
+		default:
+			return Profile{}, i18n.New("sandbox_network_unknown", override.NetworkMode)
+		}
+		if !contains(p.AllowedNetworkModes, override.NetworkMode) {
+			return Profile{}, i18n.New("sandbox_network_denied", override.NetworkMode)
+		}
+		profile.NetworkMode = override.NetworkMode
+	}
+	return profile, nil
+}
+
+// CheckImageUser rejects running as the image's default user (when the
+// profile sets none) if that user is root, unless the policy allows root.
+// imageUser is the USER of the image, empty meaning root.
+func (p Policy) CheckImageUser(profile Profile, imageUser string) error {
+	if profile.User != "" || p.AllowRoot {
+		return nil
+	}
+	if imageUser == "" || isRoot(imageUser) {
+		return i18n.New("sandbox_root_denied")
+	}
+	return nil
+}
+
+// MemoryLimit returns the memory limit in MB for a function requesting
+// requested MB (0 for the platform default).
+func (p Policy) MemoryLimit(requested int64) (int64, error) {
+	if requested == 0 {
+		return p.DefaultMemoryMB, nil
+	}
+	if requested < 6 || (p.MaxMemoryMB > 0 && requested > p.MaxMemoryMB) {
+		return 0, i18n.New("sandbox_memory_out_of_range", p.MaxMemoryMB)
+	}
+	return requested, nil
+}
+
+var tmpfsSizePattern = regexp.MustCompile(`^(\d+)([kmg]?)$`)
+
+// tmpfsBytes parses a tmpfs size (bytes, or with a k, m or g suffix) and
+// checks it against MaxTmpfsMB. Anything else is rejected, since the size
+// ends up in the mount options of the container.
+func (p Policy) tmpfsBytes(size string) (int64, error) {
+	match := tmpfsSizePattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, i18n.New("sandbox_tmpfs_invalid", size, p.MaxTmpfsMB)
+	}
+	bytes, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, i18n.New("sandbox_tmpfs_invalid", size, p.MaxTmpfsMB)
+	}
+	shift := map[string]uint{"": 0, "k": 10, "m": 20, "g": 30}[match[2]]
+	if bytes == 0 || bytes > math.MaxInt64>>shift || (p.MaxTmpfsMB > 0 && bytes<<shift > p.MaxTmpfsMB<<20) {
+		return 0, i18n.New("sandbox_tmpfs_invalid", size, p.MaxTmpfsMB)
+	}
+	return bytes << shift, nil
+}
+
+// Apply sets the sandbox options on the container configuration.
+func (p Policy) Apply(profile Profile, config *container.Config, hostConfig *container.HostConfig) error {
+	config.User = profile.User
+
+	hostConfig.ReadonlyRootfs = profile.ReadOnlyRootFS
+	tmpfsSize, err := p.tmpfsBytes(profile.TmpfsSize)
+	if err != nil {
+		return err
+	}
+	hostConfig.Tmpfs = map[string]string{
+		"/tmp": "rw,noexec,nosuid,nodev,size=" + strconv.FormatInt(tmpfsSize, 10),
+	}
+	hostConfig.CapDrop = []string{"ALL"}
+	hostConfig.CapAdd = profile.CapAdd
+	hostConfig.SecurityOpt = []string{"no-new-privileges"}
+	hostConfig.Runtime = profile.Runtime
+
+	if profile.SeccompProfile != "" {
+		// The Docker API expects the profile contents, not a path
+		seccomp, err := os.ReadFile(filepath.Join(p.SeccompDir, profile.SeccompProfile+".json"))
+		if err != nil {
+			return fmt.Errorf("no se pudo leer el perfil seccomp %s: %w", profile.SeccompProfile, err)
+		}
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+string(seccomp))
+	}
+
+	switch profile.NetworkMode {
+	case NetworkNone:
+		hostConfig.NetworkMode = container.NetworkMode("none")
+	case NetworkEgress:
+		hostConfig.NetworkMode = container.NetworkMode(p.EgressNetwork)
+	case NetworkInternal:
+		hostConfig.NetworkMode = container.NetworkMode(p.InternalNetwork)
+	default:
+		return fmt.Errorf("modo de red desconocido: %s", profile.NetworkMode)
+	}
+	return nil
+}
+
+// EnsureEgressNetwork creates the isolated egress bridge if it does not
+// exist. Inter-container traffic is disabled so functions cannot reach each
+// other either.
+func (p Policy) EnsureEgressNetwork(ctx context.Context, dockerClient *client.Client) error {
+	_, err := dockerClient.NetworkInspect(ctx, p.EgressNetwork, types.NetworkInspectOptions{})
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
+		return err
+	}
+	_, err = dockerClient.NetworkCreate(ctx, p.EgressNetwork, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Options: map[string]string{
+			"com.docker.network.bridge.enable_icc": "false",
+		},
+		Labels: map[string]string{"faas.network": NetworkEgress},
+	})
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return err
+	}
+	return nil
+}
+
+func isRoot(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	if name == "root" {
+		return true
+	}
+	// "00", "+0"... are uid 0 too
+	uid, err := strconv.Atoi(name)
+	return err == nil && uid == 0
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt64(name string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func envList(name string, fallback []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,154 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+
+	"faas-project/internal/i18n"
+	"faas-project/internal/models"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		Default: Profile{
+			ReadOnlyRootFS: true,
+			TmpfsSize:      "64m",
+			User:           "65534:65534",
+			NetworkMode:    NetworkEgress,
+		},
+		AllowedNetworkModes: []string{NetworkNone, NetworkEgress},
+		AllowedRuntimes:     []string{"runsc"},
+		AllowedCapabilities: []string{"NET_BIND_SERVICE"},
+		MaxTmpfsMB:          256,
+	}
+}
+
+func TestResolve(t *testing.T) {
+	no := false
+	tests := []struct {
+		name     string
+		policy   func(*Policy)
+		override *models.SecurityProfile
+		want     func(*Profile)
+		code     string
+	}{
+		{name: "no override"},
+		{name: "empty override", override: &models.SecurityProfile{}},
+		{name: "writable rootfs denied", override: &models.SecurityProfile{ReadOnlyRootFS: &no}, code: "sandbox_writable_rootfs"},
+		{
+			name:     "writable rootfs allowed",
+			policy:   func(p *Policy) { p.AllowWritableRootFS = true },
+			override: &models.SecurityProfile{ReadOnlyRootFS: &no},
+			want:     func(p *Profile) { p.ReadOnlyRootFS = false },
+		},
+		{name: "tmpfs", override: &models.SecurityProfile{TmpfsSize: "128m"}, want: func(p *Profile) { p.TmpfsSize = "128m" }},
+		{name: "tmpfs in bytes", override: &models.SecurityProfile{TmpfsSize: "1048576"}, want: func(p *Profile) { p.TmpfsSize = "1048576" }},
+		{name: "tmpfs at the cap", override: &models.SecurityProfile{TmpfsSize: "256m"}, want: func(p *Profile) { p.TmpfsSize = "256m" }},
+		{name: "tmpfs over the cap", override: &models.SecurityProfile{TmpfsSize: "1g"}, code: "sandbox_tmpfs_invalid"},
+		{name: "tmpfs zero", override: &models.SecurityProfile{TmpfsSize: "0"}, code: "sandbox_tmpfs_invalid"},
+		{name: "tmpfs with options", override: &models.SecurityProfile{TmpfsSize: "64m,exec"}, code: "sandbox_tmpfs_invalid"},
+		{name: "tmpfs unit", override: &models.SecurityProfile{TmpfsSize: "64M"}, code: "sandbox_tmpfs_invalid"},
+		{name: "tmpfs overflow", override: &models.SecurityProfile{TmpfsSize: "99999999999999999999g"}, code: "sandbox_tmpfs_invalid"},
+		{
+			name:     "tmpfs without cap",
+			policy:   func(p *Policy) { p.MaxTmpfsMB = 0 },
+			override: &models.SecurityProfile{TmpfsSize: "4g"},
+			want:     func(p *Profile) { p.TmpfsSize = "4g" },
+		},
+		{
+			name:     "allowed capability",
+			override: &models.SecurityProfile{AddCapabilities: []string{"cap_net_bind_service"}},
+			want:     func(p *Profile) { p.CapAdd = []string{"NET_BIND_SERVICE"} },
+		},
+		{name: "denied capability", override: &models.SecurityProfile{AddCapabilities: []string{"SYS_ADMIN"}}, code: "sandbox_capability_denied"},
+		{name: "user", override: &models.SecurityProfile{User: "1000:1000"}, want: func(p *Profile) { p.User = "1000:1000" }},
+		{name: "root denied", override: &models.SecurityProfile{User: "0:0"}, code: "sandbox_root_denied"},
+		{name: "root by name denied", override: &models.SecurityProfile{User: "root"}, code: "sandbox_root_denied"},
+		{name: "root padded denied", override: &models.SecurityProfile{User: "00"}, code: "sandbox_root_denied"},
+		{name: "root padded with group denied", override: &models.SecurityProfile{User: "000:0"}, code: "sandbox_root_denied"},
+		{name: "root signed denied", override: &models.SecurityProfile{User: "+0"}, code: "sandbox_root_denied"},
+		{name: "root group only", override: &models.SecurityProfile{User: "1000:0"}, want: func(p *Profile) { p.User = "1000:0" }},
+		{
+			name:     "root allowed",
+			policy:   func(p *Policy) { p.AllowRoot = true },
+			override: &models.SecurityProfile{User: "root"},
+			want:     func(p *Profile) { p.User = "root" },
+		},
+		{name: "seccomp", override: &models.SecurityProfile{SeccompProfile: "strict"}, want: func(p *Profile) { p.SeccompProfile = "strict" }},
+		{name: "seccomp unconfined", override: &models.SecurityProfile{SeccompProfile: "unconfined"}, code: "sandbox_seccomp_denied"},
+		{name: "seccomp path", override: &models.SecurityProfile{SeccompProfile: "../etc/passwd"}, code: "sandbox_seccomp_denied"},
+		{name: "runtime", override: &models.SecurityProfile{Runtime: "runsc"}, want: func(p *Profile) { p.Runtime = "runsc" }},
+		{name: "runtime denied", override: &models.SecurityProfile{Runtime: "runc"}, code: "sandbox_runtime_denied"},
+		{name: "network", override: &models.SecurityProfile{NetworkMode: NetworkNone}, want: func(p *Profile) { p.NetworkMode = NetworkNone }},
+		{name: "network denied", override: &models.SecurityProfile{NetworkMode: NetworkInternal}, code: "sandbox_network_denied"},
+		{name: "network unknown", override: &models.SecurityProfile{NetworkMode: "host"}, code: "sandbox_network_unknown"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := testPolicy()
+			if test.policy != nil {
+				test.policy(&policy)
+			}
+			profile, err := policy.Resolve(test.override)
+			code, _ := i18n.Code(err)
+			if code != test.code {
+				t.Fatalf("Resolve() error = %v, want code %q", err, test.code)
+			}
+			if test.code != "" {
+				return
+			}
+			want := policy.Default
+			if test.want != nil {
+				test.want(&want)
+			}
+			if !reflect.DeepEqual(profile, want) {
+				t.Errorf("Resolve() = %+v, want %+v", profile, want)
+			}
+		})
+	}
+}
+
+func TestResolveDoesNotShareDefaultCapabilities(t *testing.T) {
+	policy := testPolicy()
+	policy.Default.CapAdd = make([]string, 0, 4)
+	first, err := policy.Resolve(&models.SecurityProfile{AddCapabilities: []string{"NET_BIND_SERVICE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := policy.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.CapAdd) != 1 || len(second.CapAdd) != 0 || len(policy.Default.CapAdd) != 0 {
+		t.Errorf("capabilities leaked between profiles: %v, %v, default %v", first.CapAdd, second.CapAdd, policy.Default.CapAdd)
+	}
+}
+
+func TestCheckImageUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowRoot bool
+		user      string
+		imageUser string
+		code      string
+	}{
+		{name: "profile user", user: "65534:65534", imageUser: "", code: ""},
+		{name: "image user", imageUser: "app", code: ""},
+		{name: "image numeric user", imageUser: "1000:1000", code: ""},
+		{name: "image without user", imageUser: "", code: "sandbox_root_denied"},
+		{name: "image root", imageUser: "root", code: "sandbox_root_denied"},
+		{name: "image uid 0", imageUser: "0:0", code: "sandbox_root_denied"},
+		{name: "image root allowed", allowRoot: true, imageUser: "", code: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := testPolicy()
+			policy.AllowRoot = test.allowRoot
+			err := policy.CheckImageUser(Profile{User: test.user}, test.imageUser)
+			code, _ := i18n.Code(err)
+			if code != test.code {
+				t.Errorf("CheckImageUser(%q, %q) = %v, want code %q", test.user, test.imageUser, err, test.code)
+			}
+		})
+	}
+}